@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"io"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"k8s.io/apimachinery/pkg/util/wait"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/cloud-provider/app"
 	"k8s.io/cloud-provider/options"
@@ -22,11 +25,19 @@ import (
 	"k8s.io/klog/v2"
 
 	"github.com/metal-stack/metal-ccm/metal"
+	"github.com/metal-stack/metal-ccm/pkg/metrics"
 	"github.com/metal-stack/metal-ccm/pkg/resources/constants"
+	"github.com/metal-stack/metal-ccm/pkg/tracing"
 	"github.com/metal-stack/v"
 	"github.com/spf13/pflag"
 )
 
+// housekeepingShutdownTimeout is the time running housekeeping tasks get to finish after a termination signal.
+const housekeepingShutdownTimeout = 20 * time.Second
+
+// provider is the initialized cloud provider, it is shut down gracefully on exit
+var provider cloudprovider.Interface
+
 func main() {
 	opts, err := options.NewCloudControllerManagerOptions()
 	if err != nil {
@@ -34,7 +45,27 @@ func main() {
 	}
 	opts.KubeCloudShared.CloudProvider.Name = constants.ProviderName
 
+	metrics.Register()
+
 	controllerInitializers := app.DefaultInitFuncConstructors
+	controllerInitializers[metal.HealthControllerName] = app.ControllerInitFuncConstructor{
+		Constructor: metal.StartHealthControllerWrapper,
+	}
+	controllerInitializers[metal.TagSyncControllerName] = app.ControllerInitFuncConstructor{
+		Constructor: metal.StartTagSyncControllerWrapper,
+	}
+	controllerInitializers[metal.SSHKeySyncControllerName] = app.ControllerInitFuncConstructor{
+		Constructor: metal.StartSSHKeySyncControllerWrapper,
+	}
+	controllerInitializers[metal.AnnotationSyncControllerName] = app.ControllerInitFuncConstructor{
+		Constructor: metal.StartAnnotationSyncControllerWrapper,
+	}
+	controllerInitializers[metal.LoadBalancerSyncControllerName] = app.ControllerInitFuncConstructor{
+		Constructor: metal.StartLoadBalancerSyncControllerWrapper,
+	}
+	controllerInitializers[metal.NodeWatchControllerName] = app.ControllerInitFuncConstructor{
+		Constructor: metal.StartNodeWatchControllerWrapper,
+	}
 	fss := cliflag.NamedFlagSets{
 		NormalizeNameFunc: cliflag.WordSepNormalizeFunc,
 	}
@@ -42,10 +73,32 @@ func main() {
 	pflag.CommandLine.SetNormalizeFunc(cliflag.WordSepNormalizeFunc)
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 
-	command := app.NewCloudControllerManagerCommand(opts, cloudInitializer, controllerInitializers, names.CCMControllerAliases(), fss, wait.NeverStop)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	command := app.NewCloudControllerManagerCommand(opts, cloudInitializer, controllerInitializers, names.CCMControllerAliases(), fss, ctx.Done())
 
 	klog.Infof("starting version %s", v.V.String())
+
+	shutdownTracing, err := tracing.Setup(context.Background(), v.V.String())
+	if err != nil {
+		klog.Fatalf("unable to initialize tracing: %v", err)
+	}
+
 	code := cli.Run(command)
+
+	if s, ok := provider.(interface{ Shutdown(context.Context) error }); ok {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), housekeepingShutdownTimeout)
+		if err := s.Shutdown(shutdownCtx); err != nil {
+			klog.Errorf("unable to shutdown gracefully: %v", err)
+		}
+		cancel()
+	}
+
+	if err := shutdownTracing(context.Background()); err != nil {
+		klog.Errorf("unable to shutdown tracing: %v", err)
+	}
+
 	os.Exit(code)
 }
 func cloudInitializer(config *cloudcontrollerconfig.CompletedConfig) cloudprovider.Interface {
@@ -71,5 +124,6 @@ func cloudInitializer(config *cloudcontrollerconfig.CompletedConfig) cloudprovid
 		}
 	}
 
+	provider = cloud
 	return cloud
 }
@@ -1,24 +1,22 @@
 package metal
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"os"
-	"strings"
-
-	metalgo "github.com/metal-stack/metal-go"
-	"github.com/metal-stack/metal-lib/pkg/healthstatus"
 
+	"github.com/metal-stack/metal-ccm/pkg/cloudconfig"
 	"github.com/metal-stack/metal-ccm/pkg/controllers/housekeeping"
 	"github.com/metal-stack/metal-ccm/pkg/controllers/instances"
 	"github.com/metal-stack/metal-ccm/pkg/controllers/loadbalancer"
-	"github.com/metal-stack/metal-ccm/pkg/controllers/loadbalancer/config"
 	"github.com/metal-stack/metal-ccm/pkg/controllers/zones"
+	"github.com/metal-stack/metal-ccm/pkg/health"
 	"github.com/metal-stack/metal-ccm/pkg/resources/constants"
 	"github.com/metal-stack/metal-ccm/pkg/resources/metal"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/klog/v2"
@@ -29,84 +27,48 @@ import (
 	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
 )
 
-var (
-	metalclient metalgo.Client
-)
-
 type cloud struct {
+	config       *cloudconfig.CloudConfig
+	backend      metal.Backend
+	health       *health.MetalAPI
+	housekeeper  *housekeeping.Housekeeper
+	cancel       context.CancelFunc
 	instances    *instances.InstancesController
 	zones        *zones.ZonesController
 	loadBalancer *loadbalancer.LoadBalancerController
 }
 
-func NewCloud(_ io.Reader) (cloudprovider.Interface, error) {
-	url := os.Getenv(constants.MetalAPIUrlEnvVar)
-	token := os.Getenv(constants.MetalAuthTokenEnvVar)
-	hmac := os.Getenv(constants.MetalAuthHMACEnvVar)
-	hmacAuthType := os.Getenv(constants.MetalAuthHMACAuthTypeEnvVar)
-	projectID := os.Getenv(constants.MetalProjectIDEnvVar)
-	partitionID := os.Getenv(constants.MetalPartitionIDEnvVar)
-	clusterID := os.Getenv(constants.MetalClusterIDEnvVar)
-	defaultExternalNetworkID := os.Getenv(constants.MetalDefaultExternalNetworkEnvVar)
-	loadbalancerType, err := config.LoadBalancerTypeFromString(os.Getenv(constants.Loadbalancer))
+func NewCloud(configReader io.Reader) (cloudprovider.Interface, error) {
+	cfg, err := cloudconfig.Load(configReader)
 	if err != nil {
 		return nil, err
 	}
 
-	var (
-		additionalNetworksString = os.Getenv(constants.MetalAdditionalNetworks)
-		additionalNetworks       []string
-	)
-	for n := range strings.SplitSeq(additionalNetworksString, ",") {
-		n := strings.TrimSpace(n)
-		if n != "" {
-			additionalNetworks = append(additionalNetworks, n)
-		}
-	}
-
-	if projectID == "" {
-		return nil, fmt.Errorf("environment variable %q is required", constants.MetalProjectIDEnvVar)
-	}
-
-	if partitionID == "" {
-		return nil, fmt.Errorf("environment variable %q is required", constants.MetalPartitionIDEnvVar)
-	}
-
-	if clusterID == "" {
-		return nil, fmt.Errorf("environment variable %q is required", constants.MetalClusterIDEnvVar)
-	}
-
-	if url == "" {
-		return nil, fmt.Errorf("environment variable %q is required", constants.MetalAPIUrlEnvVar)
-	}
-
-	if (token == "") == (hmac == "") {
-		return nil, fmt.Errorf("environment variable %q or %q is required", constants.MetalAuthTokenEnvVar, constants.MetalAuthHMACEnvVar)
-	}
-
-	if hmacAuthType == "" {
-		hmacAuthType = "Metal-Admin"
-	}
-
-	metalclient, err = metalgo.NewDriver(url, token, hmac, metalgo.AuthType(hmacAuthType))
+	backend, err := metal.NewBackend(cfg.MetalAPI)
 	if err != nil {
 		return nil, fmt.Errorf("unable to initialize metal ccm:%w", err)
 	}
 
-	resp, err := metalclient.Health().Health(nil, nil)
-	if err != nil {
-		return nil, fmt.Errorf("metal-api health endpoint not reachable:%w", err)
+	metalAPIHealth := health.NewMetalAPI(cfg.Health.FailureThreshold)
+
+	err = backend.Health(context.Background())
+	if metal.IsTLSVerificationError(err) {
+		return nil, fmt.Errorf("unable to verify the tls certificate of the metal-api at %q, configure metalAPI.transport.caFile if it is issued by a private ca: %w", cfg.MetalAPI.URL, err)
 	}
-	if resp.Payload != nil && resp.Payload.Status != nil && *resp.Payload.Status != string(healthstatus.HealthStatusHealthy) {
-		return nil, fmt.Errorf("metal-api not healthy, restarting")
+	if err != nil {
+		klog.Errorf("metal-api not healthy, starting anyway: %v", err)
 	}
 
-	instancesController := instances.New(defaultExternalNetworkID)
-	zonesController := zones.New()
-	loadBalancerController := loadbalancer.New(partitionID, projectID, clusterID, defaultExternalNetworkID, additionalNetworks, loadbalancerType)
+	zoneMapping := zones.NewMapping(cfg.Zones)
+	instancesController := instances.New(cfg.Networks.DefaultExternalNetworkID, zoneMapping, cfg.Shutdown)
+	zonesController := zones.New(zoneMapping)
+	loadBalancerController := loadbalancer.New(cfg)
 
 	klog.Info("initialized cloud controller manager")
 	return &cloud{
+		config:       cfg,
+		backend:      backend,
+		health:       metalAPIHealth,
 		instances:    instancesController,
 		zones:        zonesController,
 		loadBalancer: loadBalancerController,
@@ -119,10 +81,6 @@ func (c *cloud) Initialize(clientBuilder cloudprovider.ControllerClientBuilder,
 	scheme := runtime.NewScheme()
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
-	projectID := os.Getenv(constants.MetalProjectIDEnvVar)
-	sshPublicKey := os.Getenv(constants.MetalSSHPublicKey)
-	clusterID := os.Getenv(constants.MetalClusterIDEnvVar)
-
 	k8sClientSet := clientBuilder.ClientOrDie("cloud-controller-manager")
 	k8sRestConfig, err := clientBuilder.Config("cloud-controller-manager")
 	if err != nil {
@@ -146,8 +104,20 @@ func (c *cloud) Initialize(clientBuilder cloudprovider.ControllerClientBuilder,
 		klog.Fatalf("unable to create k8s client: %v", err)
 	}
 
-	housekeeper := housekeeping.New(metalclient, stop, c.loadBalancer, k8sClientSet, projectID, sshPublicKey, clusterID)
-	ms := metal.New(metalclient, k8sClientSet, projectID)
+	err = c.backend.WatchCredentials(stop)
+	if err != nil {
+		klog.Fatalf("unable to watch metal-api credentials: %v", err)
+	}
+
+	ms := metal.New(c.backend, k8sClientSet, c.config.ProjectID, c.health, metal.NewGuard(c.config.MetalAPI))
+	// the housekeeping tasks are started by their controllers, see housekeeping.go
+	// they are stopped when stop is closed or on Shutdown, whatever comes first
+	ctx, cancel := context.WithCancel(wait.ContextForChannel(stop))
+	c.cancel = cancel
+	c.housekeeper, err = housekeeping.New(ctx, c.backend, ms, c.loadBalancer, k8sClientSet, c.config, c.health)
+	if err != nil {
+		klog.Fatalf("unable to create housekeeper: %v", err)
+	}
 
 	c.instances.MetalService = ms
 	c.loadBalancer.K8sClientSet = k8sClientSet
@@ -155,12 +125,24 @@ func (c *cloud) Initialize(clientBuilder cloudprovider.ControllerClientBuilder,
 	c.loadBalancer.MetalService = ms
 	c.zones.MetalService = ms
 
-	go func() {
-		err := housekeeper.Run()
-		if err != nil {
-			klog.Fatalf("unable to start houskeeper: %v", err)
-		}
-	}()
+	// the load balancer controller only enqueues config updates, so the worker runs regardless of the enabled controllers
+	c.housekeeper.StartLoadBalancerConfigWorker()
+}
+
+// Shutdown stops the housekeeping tasks and waits until running tasks have returned or the given context expires.
+func (c *cloud) Shutdown(ctx context.Context) error {
+	if c.housekeeper == nil {
+		return nil
+	}
+
+	c.cancel()
+	err := c.housekeeper.Wait(ctx)
+	if err != nil {
+		return err
+	}
+
+	klog.Info("housekeeping tasks stopped")
+	return nil
 }
 
 // LoadBalancer returns a balancer interface. Also returns true if the interface is supported, false otherwise.
@@ -0,0 +1,52 @@
+package metal
+
+import (
+	"context"
+	"fmt"
+
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/controller-manager/pkg/healthz"
+
+	"github.com/metal-stack/metal-ccm/pkg/health"
+)
+
+// HealthControllerName is the name of the controller which checks the metal-api health.
+const HealthControllerName = "metal-api-health-controller"
+
+type healthController struct {
+	health *health.MetalAPI
+	// failLiveness registers the metal-api health with the healthz endpoint, which is usually the liveness probe
+	failLiveness bool
+}
+
+// StartHealthControllerWrapper starts the periodic metal-api health checks of the housekeeper.
+// The metal-api health is only registered with the healthz endpoint of the cloud controller manager if exiting
+// on failed health checks is configured, otherwise an outage of the metal-api would restart the ccm.
+func StartHealthControllerWrapper(_ app.ControllerInitContext, _ *cloudcontrollerconfig.CompletedConfig, cloudProvider cloudprovider.Interface) app.InitFunc {
+	return func(ctx context.Context, _ genericcontrollermanager.ControllerContext) (controller.Interface, bool, error) {
+		c, ok := cloudProvider.(*cloud)
+		if !ok {
+			return nil, false, fmt.Errorf("unexpected cloud provider %T", cloudProvider)
+		}
+		c.housekeeper.StartHealthCheck(ctx)
+		return &healthController{health: c.health, failLiveness: c.config.Health.ExitAfterFailures > 0}, true, nil
+	}
+}
+
+// Name returns the name of the controller.
+func (h *healthController) Name() string {
+	return HealthControllerName
+}
+
+// HealthChecker returns the metal-api health check if it should fail the liveness of the ccm,
+// otherwise nil, such that the controller is only checked for running.
+func (h *healthController) HealthChecker() healthz.UnnamedHealthChecker {
+	if !h.failLiveness {
+		return nil
+	}
+	return h.health
+}
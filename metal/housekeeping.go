@@ -0,0 +1,86 @@
+package metal
+
+import (
+	"context"
+	"fmt"
+
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+
+	"github.com/metal-stack/metal-ccm/pkg/controllers/housekeeping"
+)
+
+const (
+	// TagSyncControllerName is the name of the controller which syncs machine tags to node labels.
+	TagSyncControllerName = "metal-tag-sync-controller"
+	// SSHKeySyncControllerName is the name of the controller which syncs the ssh public key to the machines.
+	SSHKeySyncControllerName = "metal-ssh-key-sync-controller"
+	// AnnotationSyncControllerName is the name of the controller which syncs machine details to node annotations.
+	AnnotationSyncControllerName = "metal-annotation-sync-controller"
+	// LoadBalancerSyncControllerName is the name of the controller which periodically writes the load balancer config.
+	LoadBalancerSyncControllerName = "metal-loadbalancer-sync-controller"
+	// NodeWatchControllerName is the name of the controller which reacts to added and changed nodes.
+	NodeWatchControllerName = "metal-node-watch-controller"
+)
+
+// housekeepingController is a housekeeping task of the metal-ccm, which is run as a controller of the cloud controller manager.
+// Like all controllers, it is only started on the leader and can be disabled with the --controllers flag.
+type housekeepingController struct {
+	name string
+}
+
+// Name returns the name of the controller.
+func (h *housekeepingController) Name() string {
+	return h.name
+}
+
+// startHousekeepingControllerWrapper returns a constructor for the controller with the given name, which runs the housekeeping task started by the given function.
+// The task is stopped when the context of the controller is cancelled, e.g. on shutdown or when the leadership is lost.
+// start returns false if the task is not configured and is therefore skipped.
+func startHousekeepingControllerWrapper(name string, start func(ctx context.Context, h *housekeeping.Housekeeper) (bool, error)) app.InitFuncConstructor {
+	return func(_ app.ControllerInitContext, _ *cloudcontrollerconfig.CompletedConfig, cloudProvider cloudprovider.Interface) app.InitFunc {
+		return func(ctx context.Context, _ genericcontrollermanager.ControllerContext) (controller.Interface, bool, error) {
+			c, ok := cloudProvider.(*cloud)
+			if !ok {
+				return nil, false, fmt.Errorf("unexpected cloud provider %T", cloudProvider)
+			}
+
+			started, err := start(ctx, c.housekeeper)
+			if err != nil {
+				return nil, false, fmt.Errorf("unable to start %s: %w", name, err)
+			}
+
+			return &housekeepingController{name: name}, started, nil
+		}
+	}
+}
+
+var (
+	// StartTagSyncControllerWrapper starts the periodic sync of machine tags to node labels.
+	StartTagSyncControllerWrapper = startHousekeepingControllerWrapper(TagSyncControllerName, func(ctx context.Context, h *housekeeping.Housekeeper) (bool, error) {
+		h.StartTagSynching(ctx)
+		return true, nil
+	})
+	// StartSSHKeySyncControllerWrapper starts the periodic sync of the ssh public key to the machines, it is skipped if no ssh public key is configured.
+	StartSSHKeySyncControllerWrapper = startHousekeepingControllerWrapper(SSHKeySyncControllerName, func(ctx context.Context, h *housekeeping.Housekeeper) (bool, error) {
+		return h.StartSSHKeysSynching(ctx), nil
+	})
+	// StartAnnotationSyncControllerWrapper starts the periodic sync of machine details to node annotations.
+	StartAnnotationSyncControllerWrapper = startHousekeepingControllerWrapper(AnnotationSyncControllerName, func(ctx context.Context, h *housekeeping.Housekeeper) (bool, error) {
+		h.StartAnnotationSynching(ctx)
+		return true, nil
+	})
+	// StartLoadBalancerSyncControllerWrapper starts the periodic update of the load balancer config.
+	StartLoadBalancerSyncControllerWrapper = startHousekeepingControllerWrapper(LoadBalancerSyncControllerName, func(ctx context.Context, h *housekeeping.Housekeeper) (bool, error) {
+		h.StartLoadBalancerConfigSynching(ctx)
+		return true, nil
+	})
+	// StartNodeWatchControllerWrapper starts watching nodes to sync the tags of added nodes and update the load balancer config on address changes.
+	StartNodeWatchControllerWrapper = startHousekeepingControllerWrapper(NodeWatchControllerName, func(ctx context.Context, h *housekeeping.Housekeeper) (bool, error) {
+		err := h.WatchNodes(ctx)
+		return err == nil, err
+	})
+)
@@ -0,0 +1,537 @@
+package cloudconfig
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+
+	"github.com/metal-stack/metal-ccm/pkg/controllers/loadbalancer/config"
+	"github.com/metal-stack/metal-ccm/pkg/resources/constants"
+	"github.com/metal-stack/metal-ccm/pkg/tags"
+)
+
+const (
+	// APIVersion is the currently supported version of the cloud config format
+	APIVersion = "metal-ccm.metal-stack.io/v1alpha1"
+	// Kind is the kind of the cloud config format
+	Kind = "CloudConfig"
+
+	// MetalAPIVersionV1 is the swagger based metal-api, accessed through metal-go
+	MetalAPIVersionV1 = "v1"
+
+	defaultHMACAuthType           = "Metal-Admin"
+	defaultHealthFailureThreshold = 3
+	defaultQPS                    = 10
+	defaultBurst                  = 20
+	defaultBreakerThreshold       = 5
+
+	// ZoneSourcePartition uses the partition of a machine as its zone
+	ZoneSourcePartition = "partition"
+	// ZoneSourceRack uses the rack of a machine as its zone
+	ZoneSourceRack = "rack"
+	// ZoneSourceSwitchPair uses the leaf switches a machine is connected to as its zone
+	ZoneSourceSwitchPair = "switch-pair"
+
+	// UnknownLivelinessRunning considers machines with unknown liveliness as running
+	UnknownLivelinessRunning = "running"
+	// UnknownLivelinessShutdown considers machines with unknown liveliness as shut down
+	UnknownLivelinessShutdown = "shutdown"
+)
+
+// defaultLabelSyncExclude are machine tags which are not synced to node labels by default because they are managed by gardener
+var defaultLabelSyncExclude = []string{"networking.gardener.cloud/node-local-dns-enabled"}
+
+// CloudConfig is the configuration of the metal-ccm, it is passed through the --cloud-config flag.
+// Most of the fields can be overridden by environment variables.
+type CloudConfig struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	// MetalAPI configures the connection to the metal-api
+	MetalAPI MetalAPI `json:"metalAPI"`
+
+	// ProjectID is the project in which the cluster is running
+	ProjectID string `json:"projectID"`
+	// PartitionID is the partition in which the cluster is running
+	PartitionID string `json:"partitionID"`
+	// ClusterID is the id of the cluster
+	ClusterID string `json:"clusterID"`
+	// SSHPublicKey is synced to the machines of the cluster if set
+	SSHPublicKey string `json:"sshPublicKey,omitempty"`
+
+	// Networks configures the networks used by the cluster
+	Networks Networks `json:"networks"`
+	// LoadBalancer configures the load balancer implementation
+	LoadBalancer LoadBalancer `json:"loadBalancer"`
+	// Housekeeping configures the intervals of the housekeeping tasks
+	Housekeeping Housekeeping `json:"housekeeping"`
+	// LabelSync configures which machine tags are synced to node labels
+	LabelSync LabelSync `json:"labelSync"`
+	// Health configures how the metal-api health is judged and what happens while it is unavailable
+	Health Health `json:"health"`
+	// Zones configures how the zone and region of the nodes are derived from the machines
+	Zones Zones `json:"zones"`
+	// Shutdown configures how it is detected that the machine of a node is shut down
+	Shutdown Shutdown `json:"shutdown"`
+}
+
+// MetalAPI configures the connection to the metal-api.
+type MetalAPI struct {
+	// Version is the version of the metal-api, only v1 is supported, defaults to v1
+	Version string `json:"version,omitempty"`
+	// URL is the endpoint of the metal-api
+	URL string `json:"url"`
+	// Token is used for authentication, mutually exclusive with HMAC
+	Token string `json:"token,omitempty"`
+	// HMAC is used for authentication, mutually exclusive with Token
+	HMAC string `json:"hmac,omitempty"`
+	// TokenFile is a file containing the token, it is watched for changes and reloaded on credential rotation
+	TokenFile string `json:"tokenFile,omitempty"`
+	// HMACFile is a file containing the hmac, it is watched for changes and reloaded on credential rotation
+	HMACFile string `json:"hmacFile,omitempty"`
+	// HMACAuthType is the auth type used with the HMAC, defaults to Metal-Admin
+	HMACAuthType string `json:"hmacAuthType,omitempty"`
+	// RateLimit limits the requests sent to the metal-api
+	RateLimit RateLimit `json:"rateLimit"`
+	// CircuitBreaker configures when requests to a failing metal-api fail fast
+	CircuitBreaker CircuitBreaker `json:"circuitBreaker"`
+	// Transport configures tls, proxy and timeouts of the connection to the metal-api
+	Transport Transport `json:"transport"`
+}
+
+// Transport configures the http connection to the metal-api.
+type Transport struct {
+	// CAFile contains pem encoded certificate authorities which are trusted in addition to the system roots,
+	// required if the metal-api certificate is issued by a private ca
+	CAFile string `json:"caFile,omitempty"`
+	// ClientCertFile contains a pem encoded client certificate for mutual tls, requires ClientKeyFile
+	ClientCertFile string `json:"clientCertFile,omitempty"`
+	// ClientKeyFile contains the pem encoded key of the client certificate, requires ClientCertFile
+	ClientKeyFile string `json:"clientKeyFile,omitempty"`
+	// ProxyURL is the http proxy used for the metal-api, defaults to the proxy from the HTTPS_PROXY and NO_PROXY environment variables
+	ProxyURL string `json:"proxyURL,omitempty"`
+	// Timeout is the overall timeout of a single request, defaults to 30s
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// DialTimeout is the timeout for establishing a connection, defaults to 10s
+	DialTimeout *metav1.Duration `json:"dialTimeout,omitempty"`
+	// TLSHandshakeTimeout is the timeout for the tls handshake, defaults to 10s
+	TLSHandshakeTimeout *metav1.Duration `json:"tlsHandshakeTimeout,omitempty"`
+}
+
+// RateLimit configures the client-side rate limiter shared by all metal-api requests.
+type RateLimit struct {
+	// QPS is the number of requests per second sent to the metal-api, defaults to 10
+	QPS float64 `json:"qps,omitempty"`
+	// Burst is the number of requests which may exceed the QPS for a short time, defaults to 20
+	Burst int `json:"burst,omitempty"`
+}
+
+// CircuitBreaker configures the circuit breaker for metal-api requests.
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failed requests after which requests fail fast, defaults to 5
+	FailureThreshold uint32 `json:"failureThreshold,omitempty"`
+	// OpenTimeout is the duration requests fail fast before a single request probes the metal-api again, defaults to 30s
+	OpenTimeout *metav1.Duration `json:"openTimeout,omitempty"`
+}
+
+// Networks configures the networks used by the cluster.
+type Networks struct {
+	// DefaultExternalNetworkID is the network in which ips for services are acquired by default
+	DefaultExternalNetworkID string `json:"defaultExternalNetworkID,omitempty"`
+	// AdditionalNetworks are the networks whose ips are announced by the load balancer
+	AdditionalNetworks []string `json:"additionalNetworks,omitempty"`
+}
+
+// LoadBalancer configures the load balancer implementation.
+type LoadBalancer struct {
+	// Type is either metallb or cilium, defaults to metallb
+	Type config.LoadBalancerType `json:"type,omitempty"`
+	// AutoAssignPoolSize is the number of free ips kept pre-allocated in the default network for the auto-assign pool, 0 disables the pool
+	AutoAssignPoolSize int `json:"autoAssignPoolSize,omitempty"`
+	// AggregateAddressPools merges contiguous addresses of the address pools into prefixes
+	AggregateAddressPools bool `json:"aggregateAddressPools,omitempty"`
+}
+
+// Housekeeping configures the intervals of the housekeeping tasks.
+type Housekeeping struct {
+	// TagSyncInterval defines how often machine tags are synced to node labels
+	TagSyncInterval *metav1.Duration `json:"tagSyncInterval,omitempty"`
+	// SSHKeySyncInterval defines how often the ssh public key is synced to the machines
+	SSHKeySyncInterval *metav1.Duration `json:"sshKeySyncInterval,omitempty"`
+	// AnnotationSyncInterval defines how often the machine details are synced to node annotations
+	AnnotationSyncInterval *metav1.Duration `json:"annotationSyncInterval,omitempty"`
+	// LoadBalancerSyncInterval defines how often the load balancer config is synced
+	LoadBalancerSyncInterval *metav1.Duration `json:"loadBalancerSyncInterval,omitempty"`
+	// HealthCheckInterval defines how often the metal-api health is checked
+	HealthCheckInterval *metav1.Duration `json:"healthCheckInterval,omitempty"`
+}
+
+// LabelSync configures which machine tags of the form key=value are synced to node labels.
+// Patterns are globs where * matches any characters, or regular expressions if they are enclosed in slashes like /^metal-stack\.io\/.+$/.
+type LabelSync struct {
+	// Include only syncs tags whose key matches one of the patterns, all tags are synced if empty
+	Include []string `json:"include,omitempty"`
+	// Exclude never syncs tags whose key matches one of the patterns, it takes precedence over Include,
+	// defaults to networking.gardener.cloud/node-local-dns-enabled
+	Exclude []string `json:"exclude,omitempty"`
+	// PrefixRewrites replaces key prefixes after the patterns were matched, e.g. machine.metal-stack.io/ with metal-stack.io/,
+	// the longest matching prefix is replaced
+	PrefixRewrites map[string]string `json:"prefixRewrites,omitempty"`
+}
+
+// Health configures how the metal-api health is judged and what happens while it is unavailable.
+type Health struct {
+	// FailureThreshold is the number of consecutive failed health checks after which the metal-api is considered unavailable, defaults to 3
+	FailureThreshold int `json:"failureThreshold,omitempty"`
+	// ExitAfterFailures terminates the ccm after the given number of consecutive failed health checks, if set the healthz
+	// endpoint also fails while the metal-api is unavailable. Defaults to 0 which keeps the ccm running in degraded mode.
+	ExitAfterFailures int `json:"exitAfterFailures,omitempty"`
+}
+
+// Zones configures how the topology.kubernetes.io/zone and topology.kubernetes.io/region labels of the nodes are derived from the machines.
+type Zones struct {
+	// Source is what forms the zone of a node, one of partition, rack or switch-pair, defaults to partition.
+	// Nodes whose rack or switch pair is unknown fall back to the zone of their partition.
+	Source string `json:"source,omitempty"`
+	// Partitions maps partition ids to an explicit region and zone, partitions which are not listed or leave a field empty
+	// use the partition id as zone and the part of the partition id before the first hyphen as region
+	Partitions map[string]PartitionZone `json:"partitions,omitempty"`
+}
+
+// PartitionZone is the explicit region and zone of a partition.
+type PartitionZone struct {
+	// Region of the nodes in the partition
+	Region string `json:"region,omitempty"`
+	// Zone of the nodes in the partition, only used as a fallback if the zone source is not partition
+	Zone string `json:"zone,omitempty"`
+}
+
+// Shutdown configures how it is detected that the machine of a node is shut down, which lets kubernetes taint the node
+// with node.cloudprovider.kubernetes.io/shutdown and detach its volumes.
+// A machine is shut down if its bmc reports it as powered off, otherwise if its liveliness is Dead.
+type Shutdown struct {
+	// PowerState queries the power state of the machines from their bmc, which requires permission to read the ipmi details of the machines.
+	// Without it, only the liveliness of the machines is considered.
+	PowerState bool `json:"powerState,omitempty"`
+	// UnknownLiveliness is how machines whose liveliness is Unknown are considered, either running or shutdown, defaults to running
+	UnknownLiveliness string `json:"unknownLiveliness,omitempty"`
+}
+
+// Load reads the cloud config from the given reader, applies the environment variable overrides and defaults and validates the result.
+// The reader may be nil if no cloud config file was given, the configuration is then solely read from the environment.
+func Load(r io.Reader) (*CloudConfig, error) {
+	c := &CloudConfig{}
+
+	if r != nil {
+		raw, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read cloud config: %w", err)
+		}
+
+		if len(strings.TrimSpace(string(raw))) > 0 {
+			err = yaml.UnmarshalStrict(raw, c)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse cloud config: %w", err)
+			}
+
+			if c.APIVersion != APIVersion || c.Kind != Kind {
+				return nil, fmt.Errorf("unsupported cloud config %q of kind %q, only %q of kind %q is supported", c.APIVersion, c.Kind, APIVersion, Kind)
+			}
+		}
+	}
+
+	err := c.applyEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.defaultAndValidate()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *CloudConfig) applyEnv() error {
+	overrideString(&c.MetalAPI.URL, constants.MetalAPIUrlEnvVar)
+	c.applyCredentialsEnv()
+	overrideString(&c.MetalAPI.HMACAuthType, constants.MetalAuthHMACAuthTypeEnvVar)
+	overrideString(&c.ProjectID, constants.MetalProjectIDEnvVar)
+	overrideString(&c.PartitionID, constants.MetalPartitionIDEnvVar)
+	overrideString(&c.ClusterID, constants.MetalClusterIDEnvVar)
+	overrideString(&c.SSHPublicKey, constants.MetalSSHPublicKey)
+	overrideString(&c.Networks.DefaultExternalNetworkID, constants.MetalDefaultExternalNetworkEnvVar)
+
+	if v, ok := os.LookupEnv(constants.MetalAdditionalNetworks); ok && v != "" {
+		c.Networks.AdditionalNetworks = nil
+		for n := range strings.SplitSeq(v, ",") {
+			n := strings.TrimSpace(n)
+			if n != "" {
+				c.Networks.AdditionalNetworks = append(c.Networks.AdditionalNetworks, n)
+			}
+		}
+	}
+
+	if v, ok := os.LookupEnv(constants.Loadbalancer); ok && v != "" {
+		c.LoadBalancer.Type = config.LoadBalancerType(v)
+	}
+
+	var errs []error
+
+	if v, ok := os.LookupEnv(constants.MetalAutoAssignPoolSize); ok && v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("environment variable %q must be a number: %w", constants.MetalAutoAssignPoolSize, err))
+		}
+		c.LoadBalancer.AutoAssignPoolSize = size
+	}
+
+	if v, ok := os.LookupEnv(constants.MetalAggregateAddressPools); ok && v != "" {
+		aggregate, err := strconv.ParseBool(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("environment variable %q must be a boolean: %w", constants.MetalAggregateAddressPools, err))
+		}
+		c.LoadBalancer.AggregateAddressPools = aggregate
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *CloudConfig) defaultAndValidate() error {
+	var errs []error
+
+	required := func(value, field, envVar string) {
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%q is required, set it in the cloud config or through the environment variable %q", field, envVar))
+		}
+	}
+
+	required(c.MetalAPI.URL, "metalAPI.url", constants.MetalAPIUrlEnvVar)
+	required(c.ProjectID, "projectID", constants.MetalProjectIDEnvVar)
+	required(c.PartitionID, "partitionID", constants.MetalPartitionIDEnvVar)
+	required(c.ClusterID, "clusterID", constants.MetalClusterIDEnvVar)
+
+	credentials := 0
+	for _, v := range []string{c.MetalAPI.Token, c.MetalAPI.HMAC, c.MetalAPI.TokenFile, c.MetalAPI.HMACFile} {
+		if v != "" {
+			credentials++
+		}
+	}
+	if credentials != 1 {
+		errs = append(errs, fmt.Errorf("exactly one of %q, %q, %q or %q is required, set it in the cloud config or through the environment variable %q, %q, %q or %q",
+			"metalAPI.token", "metalAPI.hmac", "metalAPI.tokenFile", "metalAPI.hmacFile",
+			constants.MetalAuthTokenEnvVar, constants.MetalAuthHMACEnvVar, constants.MetalAuthTokenFileEnvVar, constants.MetalAuthHMACFileEnvVar))
+	}
+
+	switch c.MetalAPI.Version {
+	case "":
+		c.MetalAPI.Version = MetalAPIVersionV1
+	case MetalAPIVersionV1:
+	default:
+		errs = append(errs, fmt.Errorf("%q must be %q", "metalAPI.version", MetalAPIVersionV1))
+	}
+
+	if c.MetalAPI.HMACAuthType == "" {
+		c.MetalAPI.HMACAuthType = defaultHMACAuthType
+	}
+
+	lbType, err := config.LoadBalancerTypeFromString(string(c.LoadBalancer.Type))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid %q: %w", "loadBalancer.type", err))
+	}
+	c.LoadBalancer.Type = lbType
+
+	if c.LoadBalancer.AutoAssignPoolSize < 0 {
+		errs = append(errs, fmt.Errorf("%q must not be negative", "loadBalancer.autoAssignPoolSize"))
+	}
+	if c.LoadBalancer.AutoAssignPoolSize > 0 && c.Networks.DefaultExternalNetworkID == "" {
+		errs = append(errs, fmt.Errorf("%q is required for the auto-assign pool, set it in the cloud config or through the environment variable %q", "networks.defaultExternalNetworkID", constants.MetalDefaultExternalNetworkEnvVar))
+	}
+
+	if c.MetalAPI.RateLimit.QPS == 0 {
+		c.MetalAPI.RateLimit.QPS = defaultQPS
+	}
+	if c.MetalAPI.RateLimit.Burst == 0 {
+		c.MetalAPI.RateLimit.Burst = defaultBurst
+	}
+	if c.MetalAPI.RateLimit.QPS < 0 {
+		errs = append(errs, fmt.Errorf("%q must be positive", "metalAPI.rateLimit.qps"))
+	}
+	if c.MetalAPI.RateLimit.Burst < 0 {
+		errs = append(errs, fmt.Errorf("%q must be positive", "metalAPI.rateLimit.burst"))
+	}
+	if c.MetalAPI.CircuitBreaker.FailureThreshold == 0 {
+		c.MetalAPI.CircuitBreaker.FailureThreshold = defaultBreakerThreshold
+	}
+
+	if (c.MetalAPI.Transport.ClientCertFile == "") != (c.MetalAPI.Transport.ClientKeyFile == "") {
+		errs = append(errs, fmt.Errorf("%q and %q must be set together", "metalAPI.transport.clientCertFile", "metalAPI.transport.clientKeyFile"))
+	}
+	if c.MetalAPI.Transport.ProxyURL != "" {
+		u, err := url.Parse(c.MetalAPI.Transport.ProxyURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("%q must be an absolute url like http://proxy:3128", "metalAPI.transport.proxyURL"))
+		}
+	}
+
+	if c.Health.FailureThreshold == 0 {
+		c.Health.FailureThreshold = defaultHealthFailureThreshold
+	}
+	if c.Health.FailureThreshold < 0 {
+		errs = append(errs, fmt.Errorf("%q must be positive", "health.failureThreshold"))
+	}
+	if c.Health.ExitAfterFailures < 0 {
+		errs = append(errs, fmt.Errorf("%q must not be negative", "health.exitAfterFailures"))
+	}
+
+	if c.LabelSync.Exclude == nil {
+		c.LabelSync.Exclude = defaultLabelSyncExclude
+	}
+	_, err = tags.NewLabelMapper(c.LabelSync.Include, c.LabelSync.Exclude, c.LabelSync.PrefixRewrites)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid %q: %w", "labelSync", err))
+	}
+
+	switch c.Zones.Source {
+	case "":
+		c.Zones.Source = ZoneSourcePartition
+	case ZoneSourcePartition, ZoneSourceRack, ZoneSourceSwitchPair:
+	default:
+		errs = append(errs, fmt.Errorf("%q must be one of %q, %q or %q", "zones.source", ZoneSourcePartition, ZoneSourceRack, ZoneSourceSwitchPair))
+	}
+	for _, id := range slices.Sorted(maps.Keys(c.Zones.Partitions)) {
+		p := c.Zones.Partitions[id]
+		if msgs := validation.IsValidLabelValue(p.Region); len(msgs) > 0 {
+			errs = append(errs, fmt.Errorf("%q is not a valid label value: %s", "zones.partitions."+id+".region", strings.Join(msgs, ", ")))
+		}
+		if msgs := validation.IsValidLabelValue(p.Zone); len(msgs) > 0 {
+			errs = append(errs, fmt.Errorf("%q is not a valid label value: %s", "zones.partitions."+id+".zone", strings.Join(msgs, ", ")))
+		}
+	}
+
+	switch c.Shutdown.UnknownLiveliness {
+	case "":
+		c.Shutdown.UnknownLiveliness = UnknownLivelinessRunning
+	case UnknownLivelinessRunning, UnknownLivelinessShutdown:
+	default:
+		errs = append(errs, fmt.Errorf("%q must be one of %q or %q", "shutdown.unknownLiveliness", UnknownLivelinessRunning, UnknownLivelinessShutdown))
+	}
+
+	interval := func(d **metav1.Duration, field string, def time.Duration) {
+		if *d == nil {
+			*d = &metav1.Duration{Duration: def}
+			return
+		}
+		if (*d).Duration <= 0 {
+			errs = append(errs, fmt.Errorf("%q must be a positive duration", field))
+		}
+	}
+
+	interval(&c.Housekeeping.TagSyncInterval, "housekeeping.tagSyncInterval", 1*time.Minute)
+	interval(&c.Housekeeping.SSHKeySyncInterval, "housekeeping.sshKeySyncInterval", 5*time.Minute)
+	interval(&c.Housekeeping.AnnotationSyncInterval, "housekeeping.annotationSyncInterval", 5*time.Minute)
+	interval(&c.Housekeeping.LoadBalancerSyncInterval, "housekeeping.loadBalancerSyncInterval", 1*time.Minute)
+	interval(&c.Housekeeping.HealthCheckInterval, "housekeeping.healthCheckInterval", 1*time.Minute)
+	interval(&c.MetalAPI.CircuitBreaker.OpenTimeout, "metalAPI.circuitBreaker.openTimeout", 30*time.Second)
+	interval(&c.MetalAPI.Transport.Timeout, "metalAPI.transport.timeout", 30*time.Second)
+	interval(&c.MetalAPI.Transport.DialTimeout, "metalAPI.transport.dialTimeout", 10*time.Second)
+	interval(&c.MetalAPI.Transport.TLSHandshakeTimeout, "metalAPI.transport.tlsHandshakeTimeout", 10*time.Second)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid cloud config: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// CredentialFiles returns the files the credentials are read from, it is empty if the credentials are given inline.
+func (m *MetalAPI) CredentialFiles() []string {
+	var files []string
+	for _, f := range []string{m.TokenFile, m.HMACFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// ReadCredentials returns the token and the hmac, reading them from the credential files if configured.
+func (m *MetalAPI) ReadCredentials() (token string, hmac string, err error) {
+	token, hmac = m.Token, m.HMAC
+
+	if m.TokenFile != "" {
+		token, err = readCredentialFile(m.TokenFile)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	if m.HMACFile != "" {
+		hmac, err = readCredentialFile(m.HMACFile)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	return token, hmac, nil
+}
+
+func readCredentialFile(path string) (string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read credential file: %w", err)
+	}
+
+	credential := strings.TrimSpace(string(raw))
+	if credential == "" {
+		return "", fmt.Errorf("credential file %q is empty", path)
+	}
+
+	return credential, nil
+}
+
+// applyCredentialsEnv sets the credentials from the environment. As only one kind of credentials may be configured,
+// credentials from the environment replace all credentials of the cloud config.
+func (c *CloudConfig) applyCredentialsEnv() {
+	credentials := []struct {
+		field  *string
+		envVar string
+	}{
+		{field: &c.MetalAPI.Token, envVar: constants.MetalAuthTokenEnvVar},
+		{field: &c.MetalAPI.HMAC, envVar: constants.MetalAuthHMACEnvVar},
+		{field: &c.MetalAPI.TokenFile, envVar: constants.MetalAuthTokenFileEnvVar},
+		{field: &c.MetalAPI.HMACFile, envVar: constants.MetalAuthHMACFileEnvVar},
+	}
+
+	fromEnv := false
+	for _, cred := range credentials {
+		if v, ok := os.LookupEnv(cred.envVar); ok && v != "" {
+			fromEnv = true
+		}
+	}
+	if !fromEnv {
+		return
+	}
+
+	for _, cred := range credentials {
+		*cred.field = ""
+		overrideString(cred.field, cred.envVar)
+	}
+}
+
+func overrideString(field *string, envVar string) {
+	if v, ok := os.LookupEnv(envVar); ok && v != "" {
+		*field = v
+	}
+}
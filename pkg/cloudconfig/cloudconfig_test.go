@@ -0,0 +1,401 @@
+package cloudconfig
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/metal-stack/metal-ccm/pkg/controllers/loadbalancer/config"
+	"github.com/metal-stack/metal-ccm/pkg/resources/constants"
+)
+
+func TestLoad(t *testing.T) {
+	defaultHousekeeping := Housekeeping{
+		TagSyncInterval:          &metav1.Duration{Duration: 1 * time.Minute},
+		SSHKeySyncInterval:       &metav1.Duration{Duration: 5 * time.Minute},
+		AnnotationSyncInterval:   &metav1.Duration{Duration: 5 * time.Minute},
+		LoadBalancerSyncInterval: &metav1.Duration{Duration: 1 * time.Minute},
+		HealthCheckInterval:      &metav1.Duration{Duration: 1 * time.Minute},
+	}
+
+	defaultRateLimit := RateLimit{
+		QPS:   10,
+		Burst: 20,
+	}
+	defaultCircuitBreaker := CircuitBreaker{
+		FailureThreshold: 5,
+		OpenTimeout:      &metav1.Duration{Duration: 30 * time.Second},
+	}
+	defaultTransport := Transport{
+		Timeout:             &metav1.Duration{Duration: 30 * time.Second},
+		DialTimeout:         &metav1.Duration{Duration: 10 * time.Second},
+		TLSHandshakeTimeout: &metav1.Duration{Duration: 10 * time.Second},
+	}
+	defaultHealth := Health{
+		FailureThreshold: 3,
+	}
+	defaultLabelSync := LabelSync{
+		Exclude: []string{"networking.gardener.cloud/node-local-dns-enabled"},
+	}
+	defaultZones := Zones{
+		Source: ZoneSourcePartition,
+	}
+	defaultShutdown := Shutdown{
+		UnknownLiveliness: UnknownLivelinessRunning,
+	}
+
+	tests := []struct {
+		name    string
+		config  string
+		env     map[string]string
+		want    *CloudConfig
+		wantErr string
+	}{
+		{
+			name: "full config",
+			config: `
+apiVersion: metal-ccm.metal-stack.io/v1alpha1
+kind: CloudConfig
+metalAPI:
+  version: v1
+  url: https://metal-api
+  hmac: secret
+  transport:
+    caFile: /etc/metal-ccm/ca.crt
+    clientCertFile: /etc/metal-ccm/tls.crt
+    clientKeyFile: /etc/metal-ccm/tls.key
+    proxyURL: http://proxy:3128
+    timeout: 1m
+projectID: project-a
+partitionID: partition-a
+clusterID: cluster-a
+sshPublicKey: ssh-ed25519 AAAA
+networks:
+  defaultExternalNetworkID: internet
+  additionalNetworks:
+  - internet
+  - mpls
+loadBalancer:
+  type: cilium
+  autoAssignPoolSize: 2
+  aggregateAddressPools: true
+housekeeping:
+  tagSyncInterval: 30s
+labelSync:
+  include:
+  - topology.metal-stack.io/*
+  - machine.metal-stack.io/*
+  exclude: []
+  prefixRewrites:
+    machine.metal-stack.io/: metal-stack.io/
+health:
+  exitAfterFailures: 60
+zones:
+  source: rack
+  partitions:
+    partition-a:
+      region: eu-central
+      zone: fra-equ01
+shutdown:
+  powerState: true
+  unknownLiveliness: shutdown
+`,
+			want: &CloudConfig{
+				APIVersion: APIVersion,
+				Kind:       Kind,
+				MetalAPI: MetalAPI{
+					Version:        MetalAPIVersionV1,
+					URL:            "https://metal-api",
+					HMAC:           "secret",
+					HMACAuthType:   "Metal-Admin",
+					RateLimit:      defaultRateLimit,
+					CircuitBreaker: defaultCircuitBreaker,
+					Transport: Transport{
+						CAFile:              "/etc/metal-ccm/ca.crt",
+						ClientCertFile:      "/etc/metal-ccm/tls.crt",
+						ClientKeyFile:       "/etc/metal-ccm/tls.key",
+						ProxyURL:            "http://proxy:3128",
+						Timeout:             &metav1.Duration{Duration: time.Minute},
+						DialTimeout:         defaultTransport.DialTimeout,
+						TLSHandshakeTimeout: defaultTransport.TLSHandshakeTimeout,
+					},
+				},
+				ProjectID:    "project-a",
+				PartitionID:  "partition-a",
+				ClusterID:    "cluster-a",
+				SSHPublicKey: "ssh-ed25519 AAAA",
+				Networks: Networks{
+					DefaultExternalNetworkID: "internet",
+					AdditionalNetworks:       []string{"internet", "mpls"},
+				},
+				LoadBalancer: LoadBalancer{
+					Type:                  config.LoadBalancerTypeCilium,
+					AutoAssignPoolSize:    2,
+					AggregateAddressPools: true,
+				},
+				Housekeeping: Housekeeping{
+					TagSyncInterval:          &metav1.Duration{Duration: 30 * time.Second},
+					SSHKeySyncInterval:       defaultHousekeeping.SSHKeySyncInterval,
+					AnnotationSyncInterval:   defaultHousekeeping.AnnotationSyncInterval,
+					LoadBalancerSyncInterval: defaultHousekeeping.LoadBalancerSyncInterval,
+					HealthCheckInterval:      defaultHousekeeping.HealthCheckInterval,
+				},
+				LabelSync: LabelSync{
+					Include:        []string{"topology.metal-stack.io/*", "machine.metal-stack.io/*"},
+					Exclude:        []string{},
+					PrefixRewrites: map[string]string{"machine.metal-stack.io/": "metal-stack.io/"},
+				},
+				Health: Health{
+					FailureThreshold:  3,
+					ExitAfterFailures: 60,
+				},
+				Zones: Zones{
+					Source:     ZoneSourceRack,
+					Partitions: map[string]PartitionZone{"partition-a": {Region: "eu-central", Zone: "fra-equ01"}},
+				},
+				Shutdown: Shutdown{
+					PowerState:        true,
+					UnknownLiveliness: UnknownLivelinessShutdown,
+				},
+			},
+		},
+		{
+			name: "environment only",
+			env: map[string]string{
+				constants.MetalAPIUrlEnvVar:          "http://metal-api",
+				constants.MetalAuthTokenEnvVar:       "token",
+				constants.MetalProjectIDEnvVar:       "project-a",
+				constants.MetalPartitionIDEnvVar:     "partition-a",
+				constants.MetalClusterIDEnvVar:       "cluster-a",
+				constants.MetalAdditionalNetworks:    "internet, mpls",
+				constants.MetalAggregateAddressPools: "true",
+			},
+			want: &CloudConfig{
+				MetalAPI: MetalAPI{
+					Version:        MetalAPIVersionV1,
+					URL:            "http://metal-api",
+					Token:          "token",
+					HMACAuthType:   "Metal-Admin",
+					RateLimit:      defaultRateLimit,
+					CircuitBreaker: defaultCircuitBreaker,
+					Transport:      defaultTransport,
+				},
+				ProjectID:   "project-a",
+				PartitionID: "partition-a",
+				ClusterID:   "cluster-a",
+				Networks: Networks{
+					AdditionalNetworks: []string{"internet", "mpls"},
+				},
+				LoadBalancer: LoadBalancer{
+					Type:                  config.LoadBalancerTypeMetalLB,
+					AggregateAddressPools: true,
+				},
+				Housekeeping: defaultHousekeeping,
+				LabelSync:    defaultLabelSync,
+				Health:       defaultHealth,
+				Zones:        defaultZones,
+				Shutdown:     defaultShutdown,
+			},
+		},
+		{
+			name: "environment overrides config",
+			config: `
+apiVersion: metal-ccm.metal-stack.io/v1alpha1
+kind: CloudConfig
+metalAPI:
+  url: http://metal-api
+  token: token
+projectID: project-a
+partitionID: partition-a
+clusterID: cluster-a
+`,
+			env: map[string]string{
+				constants.MetalProjectIDEnvVar: "project-b",
+			},
+			want: &CloudConfig{
+				APIVersion: APIVersion,
+				Kind:       Kind,
+				MetalAPI: MetalAPI{
+					Version:        MetalAPIVersionV1,
+					URL:            "http://metal-api",
+					Token:          "token",
+					HMACAuthType:   "Metal-Admin",
+					RateLimit:      defaultRateLimit,
+					CircuitBreaker: defaultCircuitBreaker,
+					Transport:      defaultTransport,
+				},
+				ProjectID:   "project-b",
+				PartitionID: "partition-a",
+				ClusterID:   "cluster-a",
+				LoadBalancer: LoadBalancer{
+					Type: config.LoadBalancerTypeMetalLB,
+				},
+				Housekeeping: defaultHousekeeping,
+				LabelSync:    defaultLabelSync,
+				Health:       defaultHealth,
+				Zones:        defaultZones,
+				Shutdown:     defaultShutdown,
+			},
+		},
+		{
+			name: "credentials from the environment replace the credentials of the config",
+			config: `
+apiVersion: metal-ccm.metal-stack.io/v1alpha1
+kind: CloudConfig
+metalAPI:
+  url: http://metal-api
+  tokenFile: /etc/metal-ccm/token
+projectID: project-a
+partitionID: partition-a
+clusterID: cluster-a
+`,
+			env: map[string]string{
+				constants.MetalAuthHMACEnvVar: "secret",
+			},
+			want: &CloudConfig{
+				APIVersion: APIVersion,
+				Kind:       Kind,
+				MetalAPI: MetalAPI{
+					Version:        MetalAPIVersionV1,
+					URL:            "http://metal-api",
+					HMAC:           "secret",
+					HMACAuthType:   "Metal-Admin",
+					RateLimit:      defaultRateLimit,
+					CircuitBreaker: defaultCircuitBreaker,
+					Transport:      defaultTransport,
+				},
+				ProjectID:   "project-a",
+				PartitionID: "partition-a",
+				ClusterID:   "cluster-a",
+				LoadBalancer: LoadBalancer{
+					Type: config.LoadBalancerTypeMetalLB,
+				},
+				Housekeeping: defaultHousekeeping,
+				LabelSync:    defaultLabelSync,
+				Health:       defaultHealth,
+				Zones:        defaultZones,
+				Shutdown:     defaultShutdown,
+			},
+		},
+		{
+			name: "unknown field",
+			config: `
+apiVersion: metal-ccm.metal-stack.io/v1alpha1
+kind: CloudConfig
+projectName: project-a
+`,
+			wantErr: `unable to parse cloud config: error unmarshaling JSON: while decoding JSON: json: unknown field "projectName"`,
+		},
+		{
+			name: "unsupported api version",
+			config: `
+apiVersion: metal-ccm.metal-stack.io/v2
+kind: CloudConfig
+`,
+			wantErr: `unsupported cloud config "metal-ccm.metal-stack.io/v2" of kind "CloudConfig", only "metal-ccm.metal-stack.io/v1alpha1" of kind "CloudConfig" is supported`,
+		},
+		{
+			name: "unsupported metal-api version",
+			config: `
+apiVersion: metal-ccm.metal-stack.io/v1alpha1
+kind: CloudConfig
+metalAPI:
+  version: v2
+  url: http://metal-api
+  token: token
+projectID: project-a
+partitionID: partition-a
+clusterID: cluster-a
+`,
+			wantErr: `invalid cloud config: "metalAPI.version" must be "v1"`,
+		},
+		{
+			name: "invalid values",
+			config: `
+apiVersion: metal-ccm.metal-stack.io/v1alpha1
+kind: CloudConfig
+metalAPI:
+  version: v3
+  url: http://metal-api
+  token: token
+  hmac: secret
+  transport:
+    clientCertFile: /etc/metal-ccm/tls.crt
+    proxyURL: proxy
+projectID: project-a
+partitionID: partition-a
+loadBalancer:
+  type: unknown
+  autoAssignPoolSize: 1
+housekeeping:
+  healthCheckInterval: 0s
+labelSync:
+  include:
+  - /[/
+zones:
+  source: chassis
+  partitions:
+    partition-a:
+      region: eu central
+shutdown:
+  unknownLiveliness: ignore
+`,
+			wantErr: `invalid cloud config: "clusterID" is required, set it in the cloud config or through the environment variable "METAL_CLUSTER_ID"
+exactly one of "metalAPI.token", "metalAPI.hmac", "metalAPI.tokenFile" or "metalAPI.hmacFile" is required, set it in the cloud config or through the environment variable "METAL_AUTH_TOKEN", "METAL_AUTH_HMAC", "METAL_AUTH_TOKEN_FILE" or "METAL_AUTH_HMAC_FILE"
+"metalAPI.version" must be "v1"
+invalid "loadBalancer.type": unknown load balancer type: unknown
+"networks.defaultExternalNetworkID" is required for the auto-assign pool, set it in the cloud config or through the environment variable "METAL_DEFAULT_EXTERNAL_NETWORK_ID"
+"metalAPI.transport.clientCertFile" and "metalAPI.transport.clientKeyFile" must be set together
+"metalAPI.transport.proxyURL" must be an absolute url like http://proxy:3128
+invalid "labelSync": invalid pattern "/[/": error parsing regexp: missing closing ]: ` + "`[`" + `
+"zones.source" must be one of "partition", "rack" or "switch-pair"
+"zones.partitions.partition-a.region" is not a valid label value: a valid label must be an empty string or consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyValue',  or 'my_value',  or '12345', regex used for validation is '(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?')
+"shutdown.unknownLiveliness" must be one of "running" or "shutdown"
+"housekeeping.healthCheckInterval" must be a positive duration`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, envVar := range []string{
+				constants.MetalAPIUrlEnvVar,
+				constants.MetalAuthTokenEnvVar,
+				constants.MetalAuthHMACEnvVar,
+				constants.MetalAuthTokenFileEnvVar,
+				constants.MetalAuthHMACFileEnvVar,
+				constants.MetalAuthHMACAuthTypeEnvVar,
+				constants.MetalProjectIDEnvVar,
+				constants.MetalPartitionIDEnvVar,
+				constants.MetalClusterIDEnvVar,
+				constants.MetalSSHPublicKey,
+				constants.MetalDefaultExternalNetworkEnvVar,
+				constants.MetalAdditionalNetworks,
+				constants.MetalAutoAssignPoolSize,
+				constants.MetalAggregateAddressPools,
+				constants.Loadbalancer,
+			} {
+				t.Setenv(envVar, tt.env[envVar])
+			}
+
+			got, err := Load(strings.NewReader(tt.config))
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatalf("expected error %q, got none", tt.wantErr)
+				}
+				if diff := cmp.Diff(tt.wantErr, err.Error()); diff != "" {
+					t.Errorf("error = %v", diff)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("diff = %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package housekeeping
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"github.com/metal-stack/metal-ccm/pkg/metrics"
+	"github.com/metal-stack/metal-ccm/pkg/resources/constants"
+	"github.com/metal-stack/metal-ccm/pkg/resources/kubernetes"
+	"github.com/metal-stack/metal-ccm/pkg/resources/metal"
+)
+
+// StartAnnotationSynching periodically syncs the machine details to node annotations.
+func (h *Housekeeper) StartAnnotationSynching(ctx context.Context) {
+	ctx, cancel := h.taskContext(ctx)
+	h.tasks.Go(func() {
+		defer cancel()
+		h.ticker.Start(ctx, metrics.TaskAnnotationSync, "annotation syncher", h.intervals.AnnotationSyncInterval.Duration, h.syncMachineAnnotations)
+	})
+}
+
+// syncMachineAnnotations writes the details of the machines to the annotations of their nodes, nodes are only updated if a detail changed.
+// If the details of the bmc can not be fetched, the bmc version annotation of the node is left as is.
+func (h *Housekeeper) syncMachineAnnotations(ctx context.Context) error {
+	klog.Info("start syncing machine details to node annotations")
+
+	nodes, err := kubernetes.GetNodes(ctx, h.k8sClient)
+	if err != nil {
+		return err
+	}
+
+	machines, err := h.getMachines(ctx, nodes)
+	if err != nil {
+		return err
+	}
+
+	updateNodeSpecBackoff := wait.Backoff{
+		Steps:    20,
+		Duration: 50 * time.Millisecond,
+		Jitter:   1.0,
+	}
+
+	for _, n := range nodes {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		machine, ok := machines[n.Name]
+		if !ok {
+			klog.Warningf("node:%s not a machine", n.Name)
+			continue
+		}
+
+		keys := metal.MachineAnnotationKeys
+		ipmi, err := h.ms.GetMachineIPMI(ctx, *machine.ID)
+		if err != nil {
+			klog.Warningf("annotation syncher failed to get bmc details of machine:%s, skipping its bmc version: %v", *machine.ID, err)
+			keys = slices.DeleteFunc(slices.Clone(keys), func(k string) bool { return k == constants.MachineBMCVersionAnnotation })
+		}
+
+		annotations := metal.MachineAnnotations(machine, ipmi)
+		if !kubernetes.NodeAnnotationsChanged(n, keys, annotations) {
+			continue
+		}
+
+		err = kubernetes.SyncNodeAnnotationsWithBackoff(ctx, h.k8sClient, n.Name, keys, annotations, updateNodeSpecBackoff)
+		if err != nil {
+			klog.Warningf("annotation syncher failed to update annotations on node:%s: %v", n.Name, err)
+			continue
+		}
+		klog.Infof("updated machine annotations of node %q", n.Name)
+	}
+
+	return nil
+}
@@ -1,45 +1,37 @@
 package housekeeping
 
 import (
+	"context"
 	"fmt"
-	"time"
 
-	"github.com/metal-stack/metal-lib/pkg/healthstatus"
 	"k8s.io/klog/v2"
-)
 
-const (
-	healthInterval = 1 * time.Minute
-	maxAPIErrors   = 60
+	"github.com/metal-stack/metal-ccm/pkg/metrics"
 )
 
-func (h *Housekeeper) runHealthCheck() {
-	go h.ticker.Start("metal-api healthcheck", healthInterval, h.stop, h.checkMetalAPIHealth)
+// StartHealthCheck periodically checks the health of the metal-api.
+func (h *Housekeeper) StartHealthCheck(ctx context.Context) {
+	ctx, cancel := h.taskContext(ctx)
+	h.tasks.Go(func() {
+		defer cancel()
+		h.ticker.Start(ctx, metrics.TaskMetalAPIHealth, "metal-api healthcheck", h.intervals.HealthCheckInterval.Duration, h.checkMetalAPIHealth)
+	})
 }
 
-func (h *Housekeeper) checkMetalAPIHealth() error {
-	klog.Infof("checking metal-api health, total errors:%d", h.metalAPIErrors)
-	resp, err := h.client.Health().Health(nil, nil)
-	if err != nil {
-		h.incrementAPIErrorAndPanic()
-		return err
+func (h *Housekeeper) checkMetalAPIHealth(ctx context.Context) error {
+	err := h.backend.Health(ctx)
+	if ctx.Err() != nil {
+		// an interrupted check says nothing about the metal-api
+		return ctx.Err()
 	}
-
-	if resp.Payload != nil && resp.Payload.Status != nil && *resp.Payload.Status == string(healthstatus.HealthStatusHealthy) {
-		h.resetAPIError()
+	failures := h.health.Report(err)
+	if err == nil {
 		return nil
 	}
-	h.incrementAPIErrorAndPanic()
-	return fmt.Errorf("metal-api is not healthy since:%d times", h.metalAPIErrors)
-}
 
-func (h *Housekeeper) incrementAPIErrorAndPanic() {
-	h.metalAPIErrors++
-	if h.metalAPIErrors > maxAPIErrors {
-		klog.Fatalf("metal-api was not healthy for more than:%d times, panic", maxAPIErrors)
+	if h.exitAfterFailures > 0 && failures >= h.exitAfterFailures {
+		klog.Fatalf("metal-api was not healthy for %d consecutive checks, exiting: %v", failures, err)
 	}
-}
 
-func (h *Housekeeper) resetAPIError() {
-	h.metalAPIErrors = 0
+	return fmt.Errorf("metal-api is not healthy since %d checks: %w", failures, err)
 }
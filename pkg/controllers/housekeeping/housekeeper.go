@@ -2,66 +2,72 @@ package housekeeping
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"time"
 
-	metalgo "github.com/metal-stack/metal-go"
+	"github.com/metal-stack/metal-lib/pkg/tag"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 
+	"github.com/metal-stack/metal-ccm/pkg/cloudconfig"
 	"github.com/metal-stack/metal-ccm/pkg/controllers/loadbalancer"
+	"github.com/metal-stack/metal-ccm/pkg/health"
 	"github.com/metal-stack/metal-ccm/pkg/resources/kubernetes"
 	"github.com/metal-stack/metal-ccm/pkg/resources/metal"
+	"github.com/metal-stack/metal-ccm/pkg/tags"
 )
 
 // Housekeeper periodically updates nodes and load balancers
 type Housekeeper struct {
-	client                     metalgo.Client
-	stop                       <-chan struct{}
-	k8sClient                  clientset.Interface
-	ticker                     *tickerSyncer
-	lbController               *loadbalancer.LoadBalancerController
-	lastTagSync                time.Time
-	lastLoadBalancerConfigSync time.Time
-	metalAPIErrors             int32
-	ms                         *metal.MetalService
-	sshPublicKey               string
-	clusterID                  string
+	ctx               context.Context
+	tasks             sync.WaitGroup
+	backend           metal.Backend
+	k8sClient         clientset.Interface
+	ticker            *tickerSyncer
+	lbController      *loadbalancer.LoadBalancerController
+	lastTagSync       time.Time
+	ms                *metal.MetalService
+	health            *health.MetalAPI
+	exitAfterFailures int
+	sshPublicKey      string
+	clusterID         string
+	labelMapper       *tags.LabelMapper
+	intervals         cloudconfig.Housekeeping
 }
 
-// New returns a new house keeper
-func New(metalClient metalgo.Client, stop <-chan struct{}, lbController *loadbalancer.LoadBalancerController, k8sClient clientset.Interface, projectID string, sshPublicKey string, clusterID string) *Housekeeper {
-	return &Housekeeper{
-		client:       metalClient,
-		stop:         stop,
-		ticker:       newTickerSyncer(),
-		lbController: lbController,
-		k8sClient:    k8sClient,
-		ms:           metal.New(metalClient, k8sClient, projectID),
-		sshPublicKey: sshPublicKey,
-		clusterID:    clusterID,
-	}
-}
-
-// Run runs the housekeeper...
-func (h *Housekeeper) Run() error {
-	h.startTagSynching()
-	h.startLoadBalancerConfigSynching()
-	h.startSSHKeysSynching()
-	err := h.watchNodes()
+// New returns a new house keeper, all tasks are stopped when the given context or the context they were started with is cancelled.
+func New(ctx context.Context, backend metal.Backend, ms *metal.MetalService, lbController *loadbalancer.LoadBalancerController, k8sClient clientset.Interface, cfg *cloudconfig.CloudConfig, metalAPIHealth *health.MetalAPI) (*Housekeeper, error) {
+	labelMapper, err := tags.NewLabelMapper(cfg.LabelSync.Include, cfg.LabelSync.Exclude, cfg.LabelSync.PrefixRewrites)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("invalid label sync config: %w", err)
 	}
-	h.runHealthCheck()
-	return nil
+
+	return &Housekeeper{
+		ctx:               ctx,
+		backend:           backend,
+		ticker:            newTickerSyncer(),
+		lbController:      lbController,
+		k8sClient:         k8sClient,
+		ms:                ms,
+		health:            metalAPIHealth,
+		exitAfterFailures: cfg.Health.ExitAfterFailures,
+		sshPublicKey:      cfg.SSHPublicKey,
+		clusterID:         cfg.ClusterID,
+		labelMapper:       labelMapper,
+		intervals:         cfg.Housekeeping,
+	}, nil
 }
 
-func (h *Housekeeper) watchNodes() error {
+// WatchNodes syncs the machine tags when a node is added and updates the bgp peers when a node is deleted or changes in a way that affects its peering.
+func (h *Housekeeper) WatchNodes(ctx context.Context) error {
 	klog.Info("start watching nodes")
 
+	ctx, cancel := h.taskContext(ctx)
+
 	informerFactory := informers.NewSharedInformerFactory(h.k8sClient, time.Second*30)
 	nodeInformer := informerFactory.Core().V1().Nodes()
 	_, err := nodeInformer.Informer().AddEventHandler(
@@ -71,7 +77,7 @@ func (h *Housekeeper) watchNodes() error {
 					return
 				}
 				klog.Info("node was added, start label syncing")
-				err := h.syncMachineTagsToNodeLabels()
+				err := h.syncMachineTagsToNodeLabels(ctx)
 				if err != nil {
 					klog.Errorf("synching tags failed: %v", err)
 					return
@@ -82,35 +88,91 @@ func (h *Housekeeper) watchNodes() error {
 				oldNode := oldObj.(*v1.Node)
 				newNode := newObj.(*v1.Node)
 
-				oldTunnelAddress, _ := kubernetes.NodeAddress(*oldNode)
-				newTunnelAddress, err := kubernetes.NodeAddress(*newNode)
-				if err != nil {
-					klog.Error("newNode does not have a tunnelAddress, ignoring")
-					return
-				}
-				if oldTunnelAddress == newTunnelAddress {
-					// node was not modified and ip address has not changed, not updating load balancer config
+				reason, changed := peeringChanged(*oldNode, *newNode)
+				if !changed {
 					return
 				}
 
-				klog.Info("node was modified and ip address has changed, updating load balancer config")
+				klog.Infof("%s of node %q changed, updating its bgp peer", reason, newNode.Name)
 
-				nodes, err := kubernetes.GetNodes(context.Background(), h.k8sClient)
-				if err != nil {
-					klog.Errorf("error listing nodes: %v", err)
-					return
+				h.lbController.EnqueuePeersUpdate(newNode.Name)
+			},
+			DeleteFunc: func(obj any) {
+				if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					obj = tombstone.Obj
 				}
-				err = h.lbController.UpdateLoadBalancerConfig(context.Background(), nodes)
-				if err != nil {
-					klog.Errorf("error updating load balancer config: %v", err)
+				node, ok := obj.(*v1.Node)
+				if !ok {
+					klog.Errorf("unexpected object of type %T in node delete event", obj)
+					return
 				}
+
+				klog.Infof("node %q was deleted, removing its bgp peer", node.Name)
+
+				h.ms.ForgetNode(node)
+				h.lbController.EnqueuePeersUpdate(node.Name)
 			},
 		},
 	)
 	if err != nil {
+		cancel()
 		return err
 	}
-	informerFactory.Start(wait.NeverStop)
-	go informerFactory.WaitForCacheSync(wait.NeverStop)
+	informerFactory.Start(ctx.Done())
+	h.tasks.Go(func() {
+		defer cancel()
+		informerFactory.WaitForCacheSync(ctx.Done())
+		<-ctx.Done()
+		// waits for the event handlers to return
+		informerFactory.Shutdown()
+	})
 	return nil
 }
+
+// taskContext returns a context for a task started with the given context, which is cancelled when either the given context
+// or the context of the housekeeper is done.
+func (h *Housekeeper) taskContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(h.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// Wait blocks until all started tasks have returned after the context of the housekeeper was cancelled.
+// It returns an error if the tasks do not finish before the given context expires.
+func (h *Housekeeper) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.tasks.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("housekeeping tasks did not finish in time: %w", ctx.Err())
+	}
+}
+
+// peeringChanged returns what changed between the two versions of a node if the change affects the bgp peer of the node.
+func peeringChanged(oldNode, newNode v1.Node) (string, bool) {
+	oldAddress, _ := kubernetes.NodeAddress(oldNode)
+	newAddress, _ := kubernetes.NodeAddress(newNode)
+
+	switch {
+	case oldAddress != newAddress:
+		return "ip address", true
+	case oldNode.Labels[tag.MachineNetworkPrimaryASN] != newNode.Labels[tag.MachineNetworkPrimaryASN]:
+		return "asn", true
+	case kubernetes.NodeReady(oldNode) != kubernetes.NodeReady(newNode):
+		// the peer is kept while the node is not ready, it is reconciled such that it is restored as soon as the node is back
+		return "readiness", true
+	case kubernetes.NodeExcludedFromLoadBalancers(oldNode) != kubernetes.NodeExcludedFromLoadBalancers(newNode):
+		return "load balancer exclusion", true
+	default:
+		return "", false
+	}
+}
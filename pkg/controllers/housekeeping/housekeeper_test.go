@@ -0,0 +1,88 @@
+package housekeeping
+
+import (
+	"testing"
+
+	"github.com/metal-stack/metal-lib/pkg/tag"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_peeringChanged(t *testing.T) {
+	node := func(address, asn string, ready v1.ConditionStatus, labels ...string) v1.Node {
+		n := v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "node-a",
+				Labels: map[string]string{tag.MachineNetworkPrimaryASN: asn},
+			},
+			Status: v1.NodeStatus{
+				Addresses:  []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: address}},
+				Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: ready}},
+			},
+		}
+		for _, l := range labels {
+			n.Labels[l] = ""
+		}
+		return n
+	}
+
+	tests := []struct {
+		name       string
+		oldNode    v1.Node
+		newNode    v1.Node
+		wantReason string
+		wantChange bool
+	}{
+		{
+			name:    "no change",
+			oldNode: node("10.0.0.1", "4200000001", v1.ConditionTrue),
+			newNode: node("10.0.0.1", "4200000001", v1.ConditionTrue, "unrelated"),
+		},
+		{
+			name:       "address changed",
+			oldNode:    node("10.0.0.1", "4200000001", v1.ConditionTrue),
+			newNode:    node("10.0.0.2", "4200000001", v1.ConditionTrue),
+			wantReason: "ip address",
+			wantChange: true,
+		},
+		{
+			name:       "asn changed",
+			oldNode:    node("10.0.0.1", "4200000001", v1.ConditionTrue),
+			newNode:    node("10.0.0.1", "4200000002", v1.ConditionTrue),
+			wantReason: "asn",
+			wantChange: true,
+		},
+		{
+			name:       "node became not ready",
+			oldNode:    node("10.0.0.1", "4200000001", v1.ConditionTrue),
+			newNode:    node("10.0.0.1", "4200000001", v1.ConditionUnknown),
+			wantReason: "readiness",
+			wantChange: true,
+		},
+		{
+			name:       "node became ready",
+			oldNode:    node("10.0.0.1", "4200000001", v1.ConditionFalse),
+			newNode:    node("10.0.0.1", "4200000001", v1.ConditionTrue),
+			wantReason: "readiness",
+			wantChange: true,
+		},
+		{
+			name:       "node was excluded",
+			oldNode:    node("10.0.0.1", "4200000001", v1.ConditionTrue),
+			newNode:    node("10.0.0.1", "4200000001", v1.ConditionTrue, v1.LabelNodeExcludeBalancers),
+			wantReason: "load balancer exclusion",
+			wantChange: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, changed := peeringChanged(tt.oldNode, tt.newNode)
+			if changed != tt.wantChange {
+				t.Errorf("peeringChanged() changed = %v, want %v", changed, tt.wantChange)
+			}
+			if reason != tt.wantReason {
+				t.Errorf("peeringChanged() reason = %q, want %q", reason, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,454 @@
+package housekeeping
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/google/go-cmp/cmp"
+	"github.com/metal-stack/metal-go/api/models"
+	"github.com/metal-stack/metal-lib/pkg/tag"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/metal-stack/metal-ccm/pkg/cloudconfig"
+	"github.com/metal-stack/metal-ccm/pkg/controllers/loadbalancer"
+	"github.com/metal-stack/metal-ccm/pkg/controllers/loadbalancer/config"
+	"github.com/metal-stack/metal-ccm/pkg/health"
+	"github.com/metal-stack/metal-ccm/pkg/resources/constants"
+	"github.com/metal-stack/metal-ccm/pkg/resources/metal"
+	"github.com/metal-stack/metal-ccm/pkg/resources/metal/fake"
+	"github.com/metal-stack/metal-ccm/pkg/tags"
+)
+
+const (
+	testProject   = "project-a"
+	testCluster   = "cluster-a"
+	testNetwork   = "internet"
+	testSSHPubKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIExample"
+)
+
+func newTestHousekeeper(t *testing.T, api *fake.MetalAPI, objects ...runtime.Object) *Housekeeper {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := metallbv1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &cloudconfig.CloudConfig{
+		ProjectID:    testProject,
+		ClusterID:    testCluster,
+		SSHPublicKey: testSSHPubKey,
+		Networks: cloudconfig.Networks{
+			DefaultExternalNetworkID: testNetwork,
+			AdditionalNetworks:       []string{testNetwork},
+		},
+		LoadBalancer: cloudconfig.LoadBalancer{
+			Type: config.LoadBalancerTypeMetalLB,
+		},
+		Housekeeping: cloudconfig.Housekeeping{
+			TagSyncInterval:          &metav1.Duration{Duration: time.Minute},
+			SSHKeySyncInterval:       &metav1.Duration{Duration: time.Minute},
+			AnnotationSyncInterval:   &metav1.Duration{Duration: time.Minute},
+			LoadBalancerSyncInterval: &metav1.Duration{Duration: time.Minute},
+			HealthCheckInterval:      &metav1.Duration{Duration: time.Minute},
+		},
+	}
+
+	clientSet := k8sfake.NewSimpleClientset(objects...)
+	metalAPIHealth := health.NewMetalAPI(3)
+	ms := metal.New(metal.NewMetalGoBackend(api.Client()), clientSet, testProject, metalAPIHealth, nil)
+
+	lb := loadbalancer.New(cfg)
+	lb.MetalService = ms
+	lb.K8sClientSet = clientSet
+	lb.K8sClient = crfake.NewClientBuilder().WithScheme(scheme).Build()
+
+	h, err := New(t.Context(), metal.NewMetalGoBackend(api.Client()), ms, lb, clientSet, cfg, metalAPIHealth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func testNodeAndMachine() (*v1.Node, *models.V1MachineResponse) {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node-a",
+			Labels: map[string]string{tag.MachineNetworkPrimaryASN: "4200000001"},
+		},
+		Spec: v1.NodeSpec{
+			ProviderID: "metal://partition-a/machine-a",
+		},
+		Status: v1.NodeStatus{
+			Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.1"}},
+		},
+	}
+	machine := &models.V1MachineResponse{
+		ID: new("machine-a"),
+		Allocation: &models.V1MachineAllocation{
+			Hostname: new("node-a"),
+			Project:  new(testProject),
+		},
+		Tags: []string{"topology.metal-stack.io/rack=rack-1", "no-label"},
+	}
+	return node, machine
+}
+
+func TestHousekeeper_syncMachineTagsToNodeLabels(t *testing.T) {
+	node, machine := testNodeAndMachine()
+	api := fake.New()
+	api.AddMachine(machine)
+	h := newTestHousekeeper(t, api, node)
+
+	err := h.syncMachineTagsToNodeLabels(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updated, err := h.k8sClient.CoreV1().Nodes().Get(context.Background(), "node-a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		tag.MachineNetworkPrimaryASN:   "4200000001",
+		"topology.metal-stack.io/rack": "rack-1",
+		constants.MachineProjectLabel:  testProject,
+	}
+	if diff := cmp.Diff(want, updated.Labels); diff != "" {
+		t.Errorf("diff = %v", diff)
+	}
+
+	m, _ := api.Machine("machine-a")
+	if !slices.Contains(m.Tags, tag.ClusterID+"="+testCluster) {
+		t.Errorf("expected cluster tag to be added to the machine, got %v", m.Tags)
+	}
+}
+
+func TestHousekeeper_syncMachineTagsToNodeLabels_RemovesStaleLabels(t *testing.T) {
+	node, machine := testNodeAndMachine()
+	node.Labels["topology.metal-stack.io/switch"] = "leaf01"
+	node.Labels["team"] = "dev"
+	node.Annotations = map[string]string{constants.ManagedLabelsAnnotation: "topology.metal-stack.io/rack,topology.metal-stack.io/switch"}
+	api := fake.New()
+	api.AddMachine(machine)
+	h := newTestHousekeeper(t, api, node)
+
+	err := h.syncMachineTagsToNodeLabels(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updated, err := h.k8sClient.CoreV1().Nodes().Get(t.Context(), "node-a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		tag.MachineNetworkPrimaryASN:   "4200000001",
+		"topology.metal-stack.io/rack": "rack-1",
+		"team":                         "dev",
+		constants.MachineProjectLabel:  testProject,
+	}
+	if diff := cmp.Diff(want, updated.Labels); diff != "" {
+		t.Errorf("diff = %v", diff)
+	}
+	if got := updated.Annotations[constants.ManagedLabelsAnnotation]; got != "metal-stack.io/project,topology.metal-stack.io/rack" {
+		t.Errorf("managed labels annotation = %q, want %q", got, "metal-stack.io/project,topology.metal-stack.io/rack")
+	}
+}
+
+func TestHousekeeper_syncMachineTagsToNodeLabels_KeepsForeignLabels(t *testing.T) {
+	node, machine := testNodeAndMachine()
+	node.Labels["team"] = "dev"
+	machine.Tags = append(machine.Tags, "team=ops")
+	api := fake.New()
+	api.AddMachine(machine)
+	h := newTestHousekeeper(t, api, node)
+
+	sync := func() *v1.Node {
+		t.Helper()
+		err := h.syncMachineTagsToNodeLabels(t.Context())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		updated, err := h.k8sClient.CoreV1().Nodes().Get(t.Context(), "node-a", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return updated
+	}
+
+	updated := sync()
+	if got := updated.Labels["team"]; got != "dev" {
+		t.Errorf("team label = %q, want %q", got, "dev")
+	}
+	if got := updated.Annotations[constants.ManagedLabelsAnnotation]; got != "metal-stack.io/project,topology.metal-stack.io/rack" {
+		t.Errorf("managed labels annotation = %q, want %q", got, "metal-stack.io/project,topology.metal-stack.io/rack")
+	}
+
+	m, _ := api.Machine("machine-a")
+	m.Tags = slices.DeleteFunc(m.Tags, func(tag string) bool { return tag == "team=ops" })
+	api.AddMachine(m)
+	// a new metal service bypasses the machine cache
+	h.ms = metal.New(metal.NewMetalGoBackend(api.Client()), h.k8sClient, testProject, h.health, nil)
+
+	updated = sync()
+	if got := updated.Labels["team"]; got != "dev" {
+		t.Errorf("team label = %q, want %q", got, "dev")
+	}
+
+	clientSet := h.k8sClient.(*k8sfake.Clientset)
+	clientSet.ClearActions()
+	sync()
+	for _, a := range clientSet.Actions() {
+		if a.GetVerb() == "update" {
+			t.Errorf("expected no update of an unchanged node, got %v", a)
+		}
+	}
+}
+
+func TestHousekeeper_syncMachineTagsToNodeLabels_Topology(t *testing.T) {
+	node, machine := testNodeAndMachine()
+	machine.Rackid = "rack-2"
+	machine.Hardware = &models.V1MachineHardware{
+		Nics: []*models.V1MachineNic{
+			{Name: new("lan0"), Neighbors: []*models.V1MachineNic{{Mac: new("aa:bb:cc:00:00:01")}}},
+			{Name: new("lan1"), Neighbors: []*models.V1MachineNic{{Mac: new("aa:bb:cc:00:00:02")}}},
+		},
+	}
+	api := fake.New()
+	api.AddMachine(machine)
+	h := newTestHousekeeper(t, api, node)
+
+	err := h.syncMachineTagsToNodeLabels(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updated, err := h.k8sClient.CoreV1().Nodes().Get(t.Context(), "node-a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		tag.MachineNetworkPrimaryASN:      "4200000001",
+		constants.TopologyRackLabel:       "rack-2",
+		constants.TopologySwitchPairLabel: "aabbcc000001-aabbcc000002",
+		constants.MachineProjectLabel:     testProject,
+	}
+	if diff := cmp.Diff(want, updated.Labels); diff != "" {
+		t.Errorf("diff = %v", diff)
+	}
+}
+
+func TestHousekeeper_syncMachineTagsToNodeLabels_Taints(t *testing.T) {
+	node, machine := testNodeAndMachine()
+	node.Spec.Taints = []v1.Taint{
+		{Key: "team", Value: "dev", Effect: v1.TaintEffectNoSchedule},
+		{Key: "metal-stack.io/stale", Effect: v1.TaintEffectNoSchedule},
+	}
+	node.Annotations = map[string]string{constants.ManagedTaintsAnnotation: "metal-stack.io/stale:NoSchedule"}
+	machine.Tags = append(machine.Tags,
+		tags.TaintPrefix+"metal-stack.io/faulty-disk=true:NoExecute",
+		tags.TaintPrefix+"team=ops:NoSchedule",
+	)
+	api := fake.New()
+	api.AddMachine(machine)
+	h := newTestHousekeeper(t, api, node)
+
+	err := h.syncMachineTagsToNodeLabels(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updated, err := h.k8sClient.CoreV1().Nodes().Get(t.Context(), "node-a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []v1.Taint{
+		{Key: "team", Value: "dev", Effect: v1.TaintEffectNoSchedule},
+		{Key: "metal-stack.io/faulty-disk", Value: "true", Effect: v1.TaintEffectNoExecute},
+	}
+	if diff := cmp.Diff(want, updated.Spec.Taints); diff != "" {
+		t.Errorf("diff = %v", diff)
+	}
+	if got := updated.Annotations[constants.ManagedTaintsAnnotation]; got != "metal-stack.io/faulty-disk:NoExecute" {
+		t.Errorf("managed taints annotation = %q, want %q", got, "metal-stack.io/faulty-disk:NoExecute")
+	}
+	if _, ok := updated.Labels[tags.TaintPrefix+"metal-stack.io/faulty-disk"]; ok {
+		t.Errorf("expected taint tags not to be synced as labels, got %v", updated.Labels)
+	}
+}
+
+func TestHousekeeper_syncMachineAnnotations(t *testing.T) {
+	node, machine := testNodeAndMachine()
+	node.Annotations = map[string]string{constants.MachineImageAnnotation: "ubuntu-22.04", "team": "dev"}
+	machine.Liveliness = new("Alive")
+	machine.Bios = &models.V1MachineBIOS{Vendor: new("American Megatrends Inc."), Version: new("3.4"), Date: new("06/21/2023")}
+	machine.Allocation.Created = new(strfmt.DateTime(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)))
+	api := fake.New()
+	api.AddMachine(machine)
+	api.SetBMCVersion("machine-a", "1.2.3")
+	h := newTestHousekeeper(t, api, node)
+
+	for range 2 {
+		err := h.syncMachineAnnotations(t.Context())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	updated, err := h.k8sClient.CoreV1().Nodes().Get(t.Context(), "node-a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		constants.MachineIDAnnotation:          "machine-a",
+		constants.MachineLivelinessAnnotation:  "Alive",
+		constants.MachineBIOSAnnotation:        "American Megatrends Inc. 3.4 06/21/2023",
+		constants.MachineAllocatedAtAnnotation: "2026-01-02T03:04:05Z",
+		constants.MachineBMCVersionAnnotation:  "1.2.3",
+		"team":                                 "dev",
+	}
+	if diff := cmp.Diff(want, updated.Annotations); diff != "" {
+		t.Errorf("diff = %v", diff)
+	}
+
+	updates := 0
+	for _, a := range h.k8sClient.(*k8sfake.Clientset).Actions() {
+		if a.GetVerb() == "update" {
+			updates++
+		}
+	}
+	if updates != 1 {
+		t.Errorf("expected the node to be updated once, got %d updates", updates)
+	}
+}
+
+func TestHousekeeper_syncSSHKeys(t *testing.T) {
+	node, machine := testNodeAndMachine()
+	api := fake.New()
+	api.AddMachine(machine)
+	h := newTestHousekeeper(t, api, node)
+
+	err := h.syncSSHKeys(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m, _ := api.Machine("machine-a")
+	if diff := cmp.Diff([]string{testSSHPubKey}, m.Allocation.SSHPubKeys); diff != "" {
+		t.Errorf("diff = %v", diff)
+	}
+}
+
+func TestHousekeeper_updateLoadBalancerConfig(t *testing.T) {
+	node, _ := testNodeAndMachine()
+	api := fake.New()
+	api.AddIP(&models.V1IPResponse{
+		Ipaddress: new("185.1.2.1"),
+		Networkid: new(testNetwork),
+		Projectid: new(testProject),
+		Type:      new(models.V1IPBaseTypeEphemeral),
+		Tags:      []string{tags.BuildClusterServiceFQNTag(testCluster, "default", "web")},
+	})
+	api.AddIP(&models.V1IPResponse{
+		Ipaddress: new("185.1.2.2"),
+		Networkid: new(testNetwork),
+		Projectid: new(testProject),
+		Type:      new(models.V1IPBaseTypeEphemeral),
+		Tags:      []string{tags.BuildClusterServiceFQNTag("other-cluster", "default", "web")},
+	})
+	h := newTestHousekeeper(t, api, node)
+
+	h.StartLoadBalancerConfigWorker()
+	err := h.updateLoadBalancerConfig(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var pools metallbv1beta1.IPAddressPoolList
+	err = wait.PollUntilContextTimeout(t.Context(), 100*time.Millisecond, 10*time.Second, true, func(ctx context.Context) (bool, error) {
+		err := h.lbController.K8sClient.List(ctx, &pools, client.InNamespace("metallb-system"))
+		return len(pools.Items) > 0, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pools.Items) != 1 {
+		t.Fatalf("expected one address pool, got %d", len(pools.Items))
+	}
+	if diff := cmp.Diff([]string{"185.1.2.1/32"}, pools.Items[0].Spec.Addresses); diff != "" {
+		t.Errorf("diff = %v", diff)
+	}
+}
+
+func TestHousekeeper_Wait(t *testing.T) {
+	node, machine := testNodeAndMachine()
+	api := fake.New()
+	api.AddMachine(machine)
+	h := newTestHousekeeper(t, api, node)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	h.ctx = ctx
+
+	h.StartTagSynching(t.Context())
+	h.StartSSHKeysSynching(t.Context())
+	h.StartAnnotationSynching(t.Context())
+	h.StartLoadBalancerConfigSynching(t.Context())
+	h.StartLoadBalancerConfigWorker()
+	err := h.WatchNodes(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cancel()
+
+	waitCtx, waitCancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer waitCancel()
+	err = h.Wait(waitCtx)
+	if err != nil {
+		t.Errorf("expected all tasks to stop, got %v", err)
+	}
+}
+
+func TestHousekeeper_WaitControllerContext(t *testing.T) {
+	node, machine := testNodeAndMachine()
+	api := fake.New()
+	api.AddMachine(machine)
+	h := newTestHousekeeper(t, api, node)
+	h.ctx = t.Context()
+
+	// the tasks are stopped by the context of their controller while the housekeeper keeps running
+	ctx, cancel := context.WithCancel(t.Context())
+
+	h.StartTagSynching(ctx)
+	h.StartSSHKeysSynching(ctx)
+	h.StartAnnotationSynching(ctx)
+	h.StartLoadBalancerConfigSynching(ctx)
+	err := h.WatchNodes(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cancel()
+
+	waitCtx, waitCancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer waitCancel()
+	err = h.Wait(waitCtx)
+	if err != nil {
+		t.Errorf("expected all tasks to stop, got %v", err)
+	}
+}
@@ -2,33 +2,28 @@ package housekeeping
 
 import (
 	"context"
-	"fmt"
-	"time"
 
-	"github.com/metal-stack/metal-ccm/pkg/resources/kubernetes"
+	"github.com/metal-stack/metal-ccm/pkg/metrics"
 )
 
-const (
-	syncLoadBalancerInterval        = 1 * time.Minute
-	syncLoadBalancerMinimalInterval = 5 * time.Second
-)
+// StartLoadBalancerConfigWorker starts the worker which reconciles the load balancer config whenever an update was requested.
+// It must always run because the load balancer controller only enqueues config updates.
+func (h *Housekeeper) StartLoadBalancerConfigWorker() {
+	h.tasks.Go(func() {
+		h.lbController.RunConfigWorker(h.ctx)
+	})
+}
 
-func (h *Housekeeper) startLoadBalancerConfigSynching() {
-	go h.ticker.Start("load balancer syncher", syncLoadBalancerInterval, h.stop, h.updateLoadBalancerConfig)
+// StartLoadBalancerConfigSynching periodically requests an update of the load balancer config.
+func (h *Housekeeper) StartLoadBalancerConfigSynching(ctx context.Context) {
+	ctx, cancel := h.taskContext(ctx)
+	h.tasks.Go(func() {
+		defer cancel()
+		h.ticker.Start(ctx, metrics.TaskLoadBalancerSync, "load balancer syncher", h.intervals.LoadBalancerSyncInterval.Duration, h.updateLoadBalancerConfig)
+	})
 }
 
-func (h *Housekeeper) updateLoadBalancerConfig() error {
-	if time.Since(h.lastLoadBalancerConfigSync) < syncLoadBalancerMinimalInterval {
-		return nil
-	}
-	nodes, err := kubernetes.GetNodes(context.Background(), h.k8sClient)
-	if err != nil {
-		return fmt.Errorf("error listing nodes: %w", err)
-	}
-	err = h.lbController.UpdateLoadBalancerConfig(context.Background(), nodes)
-	if err != nil {
-		return fmt.Errorf("error updating load balancer config: %w", err)
-	}
-	h.lastLoadBalancerConfigSync = time.Now()
+func (h *Housekeeper) updateLoadBalancerConfig(_ context.Context) error {
+	h.lbController.EnqueueConfigUpdate()
 	return nil
 }
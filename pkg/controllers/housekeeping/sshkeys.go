@@ -2,42 +2,49 @@ package housekeeping
 
 import (
 	"context"
-	"time"
-
 	"slices"
 
 	"k8s.io/klog/v2"
 
+	"github.com/metal-stack/metal-ccm/pkg/metrics"
 	"github.com/metal-stack/metal-ccm/pkg/resources/kubernetes"
-	"github.com/metal-stack/metal-go/api/client/machine"
-	"github.com/metal-stack/metal-go/api/models"
-)
-
-const (
-	// SyncTagsInterval defines how often ssh public keys are synched to metal machines
-	syncSSHKeysInterval = 5 * time.Minute
 )
 
-func (h *Housekeeper) startSSHKeysSynching() {
+// StartSSHKeysSynching periodically syncs the ssh public key to the machines, it returns false if no ssh public key is configured.
+func (h *Housekeeper) StartSSHKeysSynching(ctx context.Context) bool {
 	if len(h.sshPublicKey) == 0 {
 		klog.Warningf("ssh public keys not set, not synching back to machines")
-		return
+		return false
 	}
 
-	go h.ticker.Start("ssh public keys syncher", syncSSHKeysInterval, h.stop, h.syncSSHKeys)
+	ctx, cancel := h.taskContext(ctx)
+	h.tasks.Go(func() {
+		defer cancel()
+		h.ticker.Start(ctx, metrics.TaskSSHKeySync, "ssh public keys syncher", h.intervals.SSHKeySyncInterval.Duration, h.syncSSHKeys)
+	})
+	return true
 }
 
 // syncSSHKeys synchronizes ssh public keys to machines.
-func (h *Housekeeper) syncSSHKeys() error {
+func (h *Housekeeper) syncSSHKeys(ctx context.Context) error {
 	klog.Info("start syncing ssh public keys to machine")
 
-	nodes, err := kubernetes.GetNodes(context.Background(), h.k8sClient)
+	err := h.health.CheckMutation("sync ssh public keys")
+	if err != nil {
+		return err
+	}
+
+	nodes, err := kubernetes.GetNodes(ctx, h.k8sClient)
 	if err != nil {
 		return err
 	}
 
 	for _, n := range nodes {
-		m, err := h.ms.GetMachineFromNode(context.Background(), &n)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		m, err := h.ms.GetMachineFromNode(ctx, &n)
 
 		if err != nil {
 			klog.Warningf("unable to get machine for node:%q, not updating machine %v", n.Name, err)
@@ -54,10 +61,7 @@ func (h *Housekeeper) syncSSHKeys() error {
 			continue
 		}
 
-		_, err = h.client.Machine().UpdateMachine(machine.NewUpdateMachineParams().WithBody(&models.V1MachineUpdateRequest{
-			ID:         m.ID,
-			SSHPubKeys: []string{h.sshPublicKey},
-		}), nil)
+		err = h.ms.UpdateMachineSSHKeys(ctx, m.ID, []string{h.sshPublicKey})
 		if err != nil {
 			klog.Errorf("unable to update ssh public keys for machine %q %v", *m.Allocation.Hostname, err)
 			continue
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"strings"
 	"time"
 
@@ -11,31 +12,38 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
 
+	"github.com/metal-stack/metal-ccm/pkg/metrics"
 	"github.com/metal-stack/metal-ccm/pkg/resources/kubernetes"
+	"github.com/metal-stack/metal-ccm/pkg/resources/metal"
+	"github.com/metal-stack/metal-ccm/pkg/tags"
+	"github.com/metal-stack/metal-go/api/models"
 	metaltag "github.com/metal-stack/metal-lib/pkg/tag"
 )
 
 const (
-	// SyncTagsInterval defines how often tags are synced to nodes
-	SyncTagsInterval = 1 * time.Minute
 	// SyncTagsMinimalInterval defines the minimal interval how often tags are synced to nodes
 	SyncTagsMinimalInterval = 5 * time.Second
 )
 
-func (h *Housekeeper) startTagSynching() {
-	go h.ticker.Start("tags syncher", SyncTagsInterval, h.stop, h.syncMachineTagsToNodeLabels)
+// StartTagSynching periodically syncs the machine tags to the node labels.
+func (h *Housekeeper) StartTagSynching(ctx context.Context) {
+	ctx, cancel := h.taskContext(ctx)
+	h.tasks.Go(func() {
+		defer cancel()
+		h.ticker.Start(ctx, metrics.TaskTagSync, "tags syncher", h.intervals.TagSyncInterval.Duration, h.syncMachineTagsToNodeLabels)
+	})
 }
 
-// syncMachineTagsToNodeLabels synchronizes tags of machines in this project to labels of that node.
-func (h *Housekeeper) syncMachineTagsToNodeLabels() error {
+// syncMachineTagsToNodeLabels synchronizes tags of machines in this project to labels and taints of that node.
+func (h *Housekeeper) syncMachineTagsToNodeLabels(ctx context.Context) error {
 	klog.Info("start syncing machine tags to node labels")
 
-	nodes, err := kubernetes.GetNodes(context.Background(), h.k8sClient)
+	nodes, err := kubernetes.GetNodes(ctx, h.k8sClient)
 	if err != nil {
 		return err
 	}
 
-	machineTags, err := h.getMachineTags(nodes)
+	machines, err := h.getMachines(ctx, nodes)
 	if err != nil {
 		return err
 	}
@@ -48,22 +56,39 @@ func (h *Housekeeper) syncMachineTagsToNodeLabels() error {
 
 	var errs []error
 	for _, n := range nodes {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
 		nodeName := n.Name
-		tags, ok := machineTags[nodeName]
+		machine, ok := machines[nodeName]
 		if !ok {
 			klog.Warningf("node:%s not a machine", nodeName)
 			continue
 		}
-		labels := h.buildLabelsFromMachineTags(tags)
-		err := kubernetes.UpdateNodeLabelsWithBackoff(context.Background(), h.k8sClient, n.Name, labels, updateNodeSpecBackoff)
+		machineTagsOfNode := machine.Tags
+		labels, skipped := h.labelMapper.Labels(machineTagsOfNode)
+		// machine labels are derived from the machine itself and take precedence over tags with the same key
+		maps.Copy(labels, metal.MachineLabels(machine))
+		taints, skippedTaints := tags.Taints(machineTagsOfNode)
+		skipped = append(skipped, skippedTaints...)
+		if len(skipped) > 0 {
+			klog.Infof("skipped %d machine tags of node %q: %s", len(skipped), nodeName, skippedTagsString(skipped))
+		}
+		err := kubernetes.SyncManagedNodeLabelsWithBackoff(ctx, h.k8sClient, n.Name, labels, updateNodeSpecBackoff)
 		if err != nil {
 			klog.Warningf("tags syncher failed to update tags on node:%s: %v", nodeName, err)
 			continue
 		}
+		err = kubernetes.SyncManagedNodeTaintsWithBackoff(ctx, h.k8sClient, n.Name, taints, updateNodeSpecBackoff)
+		if err != nil {
+			klog.Warningf("tags syncher failed to update taints on node:%s: %v", nodeName, err)
+			continue
+		}
 
 		// check if machine has a cluster tag, if not add it
-		if machineClusterTag, found := metaltag.NewTagMap(tags).Value(metaltag.ClusterID); !found || machineClusterTag != h.clusterID {
-			m, err := h.ms.GetMachineFromNode(context.Background(), &n)
+		if machineClusterTag, found := metaltag.NewTagMap(machineTagsOfNode).Value(metaltag.ClusterID); !found || machineClusterTag != h.clusterID {
+			m, err := h.ms.GetMachineFromNode(ctx, &n)
 
 			if err != nil {
 				klog.Warningf("unable to get machine for node:%q, not updating machine %v", n.Name, err)
@@ -75,7 +100,7 @@ func (h *Housekeeper) syncMachineTagsToNodeLabels() error {
 				continue
 			}
 
-			err = h.ms.UpdateMachineTags(m.ID, append(tags, fmt.Sprintf("%s=%s", metaltag.ClusterID, h.clusterID)))
+			err = h.ms.UpdateMachineTags(ctx, m.ID, append(machineTagsOfNode, fmt.Sprintf("%s=%s", metaltag.ClusterID, h.clusterID)))
 			if err != nil {
 				errs = append(errs, fmt.Errorf("unable to update machine tags of node %q, due %w", n.Name, err))
 				continue
@@ -88,39 +113,28 @@ func (h *Housekeeper) syncMachineTagsToNodeLabels() error {
 	return errors.Join(errs...)
 }
 
-// getMachineTags returns all machine tags within the shoot.
-func (h *Housekeeper) getMachineTags(nodes []v1.Node) (map[string][]string, error) {
-	// FIXME set context
-	machines, err := h.ms.GetMachinesFromNodes(context.Background(), nodes)
+// getMachines returns all allocated machines within the shoot by their hostname.
+func (h *Housekeeper) getMachines(ctx context.Context, nodes []v1.Node) (map[string]*models.V1MachineResponse, error) {
+	machines, err := h.ms.GetMachinesFromNodes(ctx, nodes)
 	if err != nil {
 		return nil, err
 	}
 
-	machineTags := make(map[string][]string)
+	machinesByHostname := make(map[string]*models.V1MachineResponse)
 	for _, m := range machines {
 		if m.Allocation == nil {
 			continue
 		}
 		hostname := *m.Allocation.Hostname
-		machineTags[hostname] = m.Tags
+		machinesByHostname[hostname] = m
 	}
-	return machineTags, nil
+	return machinesByHostname, nil
 }
 
-func (h *Housekeeper) buildLabelsFromMachineTags(tags []string) map[string]string {
-	excludedLabels := map[string]bool{"networking.gardener.cloud/node-local-dns-enabled": true}
-
-	result := make(map[string]string)
-
-	for _, t := range tags {
-		label, value, found := strings.Cut(t, "=")
-		// we only add tags to the node labels that have an "="
-		// and ignore labels, that are also managed by gardener
-		if !found || excludedLabels[label] {
-			continue
-		}
-		result[label] = value
+func skippedTagsString(skipped []tags.SkippedTag) string {
+	var s []string
+	for _, t := range skipped {
+		s = append(s, t.String())
 	}
-
-	return result
+	return strings.Join(s, ", ")
 }
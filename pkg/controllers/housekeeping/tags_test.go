@@ -3,9 +3,11 @@ package housekeeping
 import (
 	"reflect"
 	"testing"
+
+	"github.com/metal-stack/metal-ccm/pkg/tags"
 )
 
-func Test_buildLabelsFromMachineTags(t *testing.T) {
+func TestHousekeeper_labelMapper(t *testing.T) {
 	tests := []struct {
 		name string
 		tags []string
@@ -29,11 +31,15 @@ func Test_buildLabelsFromMachineTags(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			h := &Housekeeper{}
-			got := h.buildLabelsFromMachineTags(tt.tags)
+			// the default exclude list of the cloud config
+			m, err := tags.NewLabelMapper(nil, []string{"networking.gardener.cloud/node-local-dns-enabled"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := m.Labels(tt.tags)
 
 			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("buildLabelsFromMachineTags() = %v, want %v", got, tt.want)
+				t.Errorf("Labels() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -1,9 +1,12 @@
 package housekeeping
 
 import (
+	"context"
 	"time"
 
 	"k8s.io/klog/v2"
+
+	"github.com/metal-stack/metal-ccm/pkg/metrics"
 )
 
 type tickerSyncer struct {
@@ -13,23 +16,35 @@ func newTickerSyncer() *tickerSyncer {
 	return &tickerSyncer{}
 }
 
-func (s *tickerSyncer) Start(name string, period time.Duration, stopCh <-chan struct{}, fn func() error) {
+// Start calls fn periodically until the context is cancelled, a running call is cancelled through its context.
+// The task is used as label value of the housekeeping metrics, the name is used for logging.
+func (s *tickerSyncer) Start(ctx context.Context, task, name string, period time.Duration, fn func(ctx context.Context) error) {
 	ticker := time.NewTicker(period)
 	defer ticker.Stop()
 
 	// manually call to avoid initial tick delay
-	if err := fn(); err != nil {
-		klog.Errorf("%s failed: %v", name, err)
-	}
+	s.run(ctx, task, name, fn)
 
 	for {
 		select {
 		case <-ticker.C:
-			if err := fn(); err != nil {
-				klog.Errorf("%s failed: %v", name, err)
-			}
-		case <-stopCh:
+			s.run(ctx, task, name, fn)
+		case <-ctx.Done():
+			klog.Infof("%s stopped", name)
 			return
 		}
 	}
 }
+
+func (s *tickerSyncer) run(ctx context.Context, task, name string, fn func(ctx context.Context) error) {
+	start := time.Now()
+	err := fn(ctx)
+	if ctx.Err() != nil {
+		// the task was interrupted by the shutdown, this is not a failure of the task
+		return
+	}
+	metrics.ObserveHousekeepingTask(task, start, err)
+	if err != nil {
+		klog.Errorf("%s failed: %v", name, err)
+	}
+}
@@ -3,8 +3,12 @@ package instances
 import (
 	"context"
 	"fmt"
+	"strings"
 
+	"github.com/metal-stack/metal-ccm/pkg/cloudconfig"
+	"github.com/metal-stack/metal-ccm/pkg/controllers/zones"
 	"github.com/metal-stack/metal-ccm/pkg/resources/metal"
+	"github.com/metal-stack/metal-ccm/pkg/tracing"
 
 	"github.com/metal-stack/metal-go/api/models"
 	mn "github.com/metal-stack/metal-lib/pkg/net"
@@ -15,21 +19,36 @@ import (
 	"k8s.io/klog/v2"
 )
 
+const (
+	livelinessAlive = "Alive"
+	livelinessDead  = "Dead"
+
+	powerStateOn  = "ON"
+	powerStateOff = "OFF"
+)
+
 type InstancesController struct {
 	defaultExternalNetwork string
+	zoneMapping            *zones.Mapping
+	shutdown               cloudconfig.Shutdown
 	MetalService           *metal.MetalService
 }
 
 // New returns a new instance controller that satisfies the kubernetes cloud provider instances interface
-func New(defaultExternalNetwork string) *InstancesController {
+func New(defaultExternalNetwork string, zoneMapping *zones.Mapping, shutdown cloudconfig.Shutdown) *InstancesController {
 	return &InstancesController{
 		defaultExternalNetwork: defaultExternalNetwork,
+		zoneMapping:            zoneMapping,
+		shutdown:               shutdown,
 	}
 }
 
 // NodeAddresses returns the addresses of the specified instance.
 func (i *InstancesController) NodeAddresses(ctx context.Context, name types.NodeName) ([]v1.NodeAddress, error) {
 	klog.Infof("NodeAddresses: nodeName %q", name)
+	ctx, span := tracing.Start(ctx, "InstancesController.NodeAddresses")
+	defer span.End()
+
 	machine, err := i.MetalService.GetMachineFromNodeName(ctx, name)
 	if err != nil {
 		return nil, err
@@ -45,6 +64,9 @@ func (i *InstancesController) NodeAddresses(ctx context.Context, name types.Node
 // services cannot be used in this method to obtain node addresses.
 func (i *InstancesController) NodeAddressesByProviderID(ctx context.Context, providerID string) ([]v1.NodeAddress, error) {
 	klog.Infof("NodeAddressesByProviderID: providerID %q", providerID)
+	ctx, span := tracing.Start(ctx, "InstancesController.NodeAddressesByProviderID")
+	defer span.End()
+
 	machine, err := i.MetalService.GetMachineFromProviderID(ctx, providerID)
 	if err != nil {
 		return nil, err
@@ -91,6 +113,9 @@ func nodeAddresses(machine *models.V1MachineResponse, defaultExternalNetwork str
 // Note that if the instance does not exist or is no longer running, we must return ("", cloudprovider.InstanceNotFound).
 func (i *InstancesController) InstanceID(ctx context.Context, nodeName types.NodeName) (string, error) {
 	klog.Infof("InstanceID: nodeName %q", nodeName)
+	ctx, span := tracing.Start(ctx, "InstancesController.InstanceID")
+	defer span.End()
+
 	machine, err := i.MetalService.GetMachineFromNodeName(ctx, nodeName)
 	if err != nil {
 		return "", err
@@ -102,6 +127,9 @@ func (i *InstancesController) InstanceID(ctx context.Context, nodeName types.Nod
 // InstanceType returns the type of the specified instance.
 func (i *InstancesController) InstanceType(ctx context.Context, nodeName types.NodeName) (string, error) {
 	klog.Infof("InstanceType: nodeName %q", nodeName)
+	ctx, span := tracing.Start(ctx, "InstancesController.InstanceType")
+	defer span.End()
+
 	machine, err := i.MetalService.GetMachineFromNodeName(ctx, nodeName)
 	if err != nil {
 		return "", err
@@ -113,6 +141,9 @@ func (i *InstancesController) InstanceType(ctx context.Context, nodeName types.N
 // InstanceTypeByProviderID returns the type of the specified instance.
 func (i *InstancesController) InstanceTypeByProviderID(ctx context.Context, providerID string) (string, error) {
 	klog.Infof("InstanceTypeByProviderID: providerID %q", providerID)
+	ctx, span := tracing.Start(ctx, "InstancesController.InstanceTypeByProviderID")
+	defer span.End()
+
 	machine, err := i.MetalService.GetMachineFromProviderID(ctx, providerID)
 	if err != nil {
 		return "", err
@@ -139,6 +170,9 @@ func (i *InstancesController) CurrentNodeName(_ context.Context, nodeName string
 // This method should still return true for machines that exist but are stopped/sleeping.
 func (i *InstancesController) InstanceExistsByProviderID(ctx context.Context, providerID string) (bool, error) {
 	klog.Infof("InstanceExistsByProviderID: providerID %q", providerID)
+	ctx, span := tracing.Start(ctx, "InstancesController.InstanceExistsByProviderID")
+	defer span.End()
+
 	machine, err := i.MetalService.GetMachineFromProviderID(ctx, providerID)
 	if err != nil {
 		return false, err
@@ -150,11 +184,14 @@ func (i *InstancesController) InstanceExistsByProviderID(ctx context.Context, pr
 // InstanceShutdownByProviderID returns true if the instance is shutdown in cloudprovider.
 func (i *InstancesController) InstanceShutdownByProviderID(ctx context.Context, providerID string) (bool, error) {
 	klog.Infof("InstanceShutdownByProviderID: providerID %q", providerID)
+	ctx, span := tracing.Start(ctx, "InstancesController.InstanceShutdownByProviderID")
+	defer span.End()
+
 	machine, err := i.MetalService.GetMachineFromProviderID(ctx, providerID)
-	if err != nil || machine.Allocation == nil {
+	if err != nil {
 		return true, err
 	}
-	return false, nil
+	return i.machineShutdown(ctx, machine), nil
 }
 
 // ------------- InstanceV2 interface functions ---------------------------
@@ -163,6 +200,9 @@ func (i *InstancesController) InstanceShutdownByProviderID(ctx context.Context,
 // Use the node.name or node.spec.providerID field to find the node in the cloud provider.
 func (i *InstancesController) InstanceExists(ctx context.Context, node *v1.Node) (bool, error) {
 	klog.Infof("InstanceExists: node %q", node.GetName())
+	ctx, span := tracing.Start(ctx, "InstancesController.InstanceExists")
+	defer span.End()
+
 	machine, err := i.MetalService.GetMachineFromProviderID(ctx, node.Spec.ProviderID)
 	if err != nil {
 		return false, err
@@ -174,11 +214,44 @@ func (i *InstancesController) InstanceExists(ctx context.Context, node *v1.Node)
 // Use the node.name or node.spec.providerID field to find the node in the cloud provider.
 func (i *InstancesController) InstanceShutdown(ctx context.Context, node *v1.Node) (bool, error) {
 	klog.Infof("InstanceShutdown: node %q", node.GetName())
+	ctx, span := tracing.Start(ctx, "InstancesController.InstanceShutdown")
+	defer span.End()
+
 	machine, err := i.MetalService.GetMachineFromProviderID(ctx, node.Spec.ProviderID)
-	if err != nil || machine.Allocation == nil {
+	if err != nil {
 		return true, err
 	}
-	return false, nil
+	return i.machineShutdown(ctx, machine), nil
+}
+
+// machineShutdown returns true if the given machine is not allocated or shut down.
+// The power state reported by the bmc takes precedence if it is queried and known, otherwise the liveliness decides,
+// an unknown liveliness is handled as configured.
+func (i *InstancesController) machineShutdown(ctx context.Context, machine *models.V1MachineResponse) bool {
+	if machine.Allocation == nil {
+		return true
+	}
+
+	if i.shutdown.PowerState {
+		state, err := i.MetalService.GetMachinePowerState(ctx, *machine.ID)
+		switch {
+		case err != nil:
+			klog.Warningf("unable to get power state of machine %q, using its liveliness: %v", *machine.ID, err)
+		case strings.EqualFold(state, powerStateOff):
+			return true
+		case strings.EqualFold(state, powerStateOn):
+			return false
+		}
+	}
+
+	switch {
+	case machine.Liveliness != nil && *machine.Liveliness == livelinessDead:
+		return true
+	case machine.Liveliness != nil && *machine.Liveliness == livelinessAlive:
+		return false
+	default:
+		return i.shutdown.UnknownLiveliness == cloudconfig.UnknownLivelinessShutdown
+	}
 }
 
 // InstanceMetadata contains metadata about a specific instance.
@@ -197,6 +270,8 @@ func (i *InstancesController) InstanceShutdown(ctx context.Context, node *v1.Nod
 // currently being set in node.spec.providerID.
 func (i *InstancesController) InstanceMetadata(ctx context.Context, node *v1.Node) (*cloudprovider.InstanceMetadata, error) {
 	klog.Infof("InstanceMetadata: node %q", node.GetName())
+	ctx, span := tracing.Start(ctx, "InstancesController.InstanceMetadata")
+	defer span.End()
 
 	machine, err := i.MetalService.GetMachineFromNode(ctx, node)
 	if err != nil {
@@ -209,10 +284,14 @@ func (i *InstancesController) InstanceMetadata(ctx context.Context, node *v1.Nod
 	if err != nil {
 		return nil, err
 	}
+	zone := i.zoneMapping.Zone(machine)
 	md := &cloudprovider.InstanceMetadata{
-		InstanceType:  *machine.Size.ID,
-		ProviderID:    fmt.Sprintf("metal://%s/%s", *machine.Partition.ID, *machine.ID),
-		NodeAddresses: nas,
+		InstanceType:     *machine.Size.ID,
+		ProviderID:       fmt.Sprintf("metal://%s/%s", *machine.Partition.ID, *machine.ID),
+		NodeAddresses:    nas,
+		Zone:             zone.FailureDomain,
+		Region:           zone.Region,
+		AdditionalLabels: metal.MachineLabels(machine),
 	}
 	return md, nil
 }
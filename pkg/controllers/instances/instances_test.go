@@ -0,0 +1,102 @@
+package instances
+
+import (
+	"testing"
+
+	"github.com/metal-stack/metal-go/api/models"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/metal-stack/metal-ccm/pkg/cloudconfig"
+	"github.com/metal-stack/metal-ccm/pkg/health"
+	"github.com/metal-stack/metal-ccm/pkg/resources/metal"
+	"github.com/metal-stack/metal-ccm/pkg/resources/metal/fake"
+)
+
+func TestInstancesController_machineShutdown(t *testing.T) {
+	tests := []struct {
+		name        string
+		shutdown    cloudconfig.Shutdown
+		unallocated bool
+		liveliness  string
+		powerState  string
+		want        bool
+	}{
+		{
+			name:        "not allocated",
+			unallocated: true,
+			liveliness:  livelinessAlive,
+			want:        true,
+		},
+		{
+			name:       "alive",
+			liveliness: livelinessAlive,
+			want:       false,
+		},
+		{
+			name:       "dead",
+			liveliness: livelinessDead,
+			want:       true,
+		},
+		{
+			name:       "unknown is running by default",
+			shutdown:   cloudconfig.Shutdown{UnknownLiveliness: cloudconfig.UnknownLivelinessRunning},
+			liveliness: "Unknown",
+			want:       false,
+		},
+		{
+			name:       "unknown is configured as shutdown",
+			shutdown:   cloudconfig.Shutdown{UnknownLiveliness: cloudconfig.UnknownLivelinessShutdown},
+			liveliness: "Unknown",
+			want:       true,
+		},
+		{
+			name:       "powered off takes precedence over liveliness",
+			shutdown:   cloudconfig.Shutdown{PowerState: true},
+			liveliness: livelinessAlive,
+			powerState: "OFF",
+			want:       true,
+		},
+		{
+			name:       "powered on takes precedence over liveliness",
+			shutdown:   cloudconfig.Shutdown{PowerState: true},
+			liveliness: livelinessDead,
+			powerState: "ON",
+			want:       false,
+		},
+		{
+			name:       "power state is ignored if not enabled",
+			liveliness: livelinessAlive,
+			powerState: "OFF",
+			want:       false,
+		},
+		{
+			name:       "liveliness is used without power state",
+			shutdown:   cloudconfig.Shutdown{PowerState: true},
+			liveliness: livelinessDead,
+			want:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := &models.V1MachineResponse{
+				ID:         new("machine-a"),
+				Liveliness: new(tt.liveliness),
+			}
+			if !tt.unallocated {
+				machine.Allocation = &models.V1MachineAllocation{Hostname: new("node-a"), Project: new("project-a")}
+			}
+			api := fake.New()
+			api.AddMachine(machine)
+			if tt.powerState != "" {
+				api.SetPowerState("machine-a", tt.powerState)
+			}
+
+			i := New("", nil, tt.shutdown)
+			i.MetalService = metal.New(metal.NewMetalGoBackend(api.Client()), k8sfake.NewSimpleClientset(), "project-a", health.NewMetalAPI(3), nil)
+
+			if got := i.machineShutdown(t.Context(), machine); got != tt.want {
+				t.Errorf("machineShutdown() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,32 +3,39 @@ package config
 import (
 	"errors"
 	"fmt"
+	"maps"
 	"net/netip"
 	"slices"
 	"strings"
 
+	"github.com/metal-stack/metal-ccm/pkg/tags"
 	"github.com/metal-stack/metal-go/api/models"
 	"github.com/metal-stack/metal-lib/pkg/pointer"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 const (
 	bgpProtocol = "bgp"
+
+	autoAssignPoolType = "auto-assign"
 )
 
 type addressPool struct {
 	Name       string
 	Protocol   string
 	AutoAssign *bool
-	CIDRs      []string // It is assumed that only host addresses (/32 for ipv4 or /128 for ipv6) are used.
+	CIDRs      []string // Only host addresses (/32 for ipv4 or /128 for ipv6) are used unless the pool was aggregated.
+
+	addresses sets.Set[netip.Addr]
 }
 
 type addressPools map[string]addressPool
 
-func newBGPAddressPool(name string) addressPool {
+func newBGPAddressPool(name string, autoAssign bool) addressPool {
 	return addressPool{
 		Name:       name,
 		Protocol:   bgpProtocol,
-		AutoAssign: new(false),
+		AutoAssign: new(autoAssign),
 	}
 }
 
@@ -42,24 +49,95 @@ func (pool *addressPool) appendIP(ip *models.V1IPResponse) error {
 		return err
 	}
 
-	cidr := fmt.Sprintf("%s/%d", parsed.String(), parsed.BitLen())
+	if pool.addresses == nil {
+		pool.addresses = sets.New[netip.Addr]()
+		for _, cidr := range pool.CIDRs {
+			prefix, err := netip.ParsePrefix(cidr)
+			if err != nil {
+				return err
+			}
+			pool.addresses.Insert(prefix.Addr())
+		}
+	}
 
-	if slices.ContainsFunc(pool.CIDRs, func(elem string) bool {
-		return cidr == elem
-	}) {
+	if pool.addresses.Has(parsed) {
 		return nil
 	}
 
-	pool.CIDRs = append(pool.CIDRs, cidr)
+	pool.addresses.Insert(parsed)
+	pool.CIDRs = append(pool.CIDRs, netip.PrefixFrom(parsed, parsed.BitLen()).String())
 
 	return nil
 }
 
+// aggregate replaces the host addresses of the pool with the smallest set of prefixes covering exactly these addresses.
+func (pool *addressPool) aggregate() {
+	var cidrs []string
+	for _, prefix := range aggregatePrefixes(slices.SortedFunc(maps.Keys(pool.addresses), netip.Addr.Compare)) {
+		cidrs = append(cidrs, prefix.String())
+	}
+	pool.CIDRs = cidrs
+}
+
+// aggregatePrefixes merges the given sorted and unique addresses into the smallest set of prefixes covering exactly these addresses.
+func aggregatePrefixes(addrs []netip.Addr) []netip.Prefix {
+	var result []netip.Prefix
+
+	for i := 0; i < len(addrs); {
+		// find the run of contiguous addresses starting at i
+		start, end := addrs[i], addrs[i]
+		for i++; i < len(addrs) && addrs[i] == end.Next(); i++ {
+			end = addrs[i]
+		}
+
+		for {
+			prefix := largestAlignedPrefix(start, end)
+			result = append(result, prefix)
+
+			last := lastAddr(prefix)
+			if last == end {
+				break
+			}
+			start = last.Next()
+		}
+	}
+
+	return result
+}
+
+// largestAlignedPrefix returns the largest prefix beginning with start that does not exceed end.
+func largestAlignedPrefix(start, end netip.Addr) netip.Prefix {
+	best := netip.PrefixFrom(start, start.BitLen())
+	for bits := start.BitLen() - 1; bits >= 0; bits-- {
+		prefix := netip.PrefixFrom(start, bits).Masked()
+		if prefix.Addr() != start || lastAddr(prefix).Compare(end) > 0 {
+			break
+		}
+		best = prefix
+	}
+	return best
+}
+
+// lastAddr returns the last address contained in the given prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	addr := prefix.Masked().Addr()
+	bytes := addr.As16()
+	offset := 128 - addr.BitLen()
+	for bit := offset + prefix.Bits(); bit < 128; bit++ {
+		bytes[bit/8] |= 1 << (7 - bit%8)
+	}
+	last := netip.AddrFrom16(bytes)
+	if addr.Is4() {
+		return last.Unmap()
+	}
+	return last
+}
+
 func (as addressPools) addPoolIP(poolName string, ip *models.V1IPResponse) error {
 
 	pool, ok := as[poolName]
 	if !ok {
-		as[poolName] = newBGPAddressPool(poolName)
+		as[poolName] = newBGPAddressPool(poolName, strings.HasSuffix(poolName, "-"+autoAssignPoolType))
 		pool = as[poolName]
 	}
 
@@ -71,11 +149,15 @@ func (as addressPools) addPoolIP(poolName string, ip *models.V1IPResponse) error
 	return nil
 }
 
-func getPoolName(network string, ip *models.V1IPResponse) string {
+func getPoolName(network, clusterID string, ip *models.V1IPResponse) string {
 	poolType := models.V1IPBaseTypeEphemeral
 	if pointer.SafeDeref(ip.Type) == models.V1IPBaseTypeStatic {
 		poolType = models.V1IPBaseTypeStatic
 	}
+	// ips of the auto-assign pool are kept in their own pool, even after they were picked up by a service
+	if tags.IsAutoAssign(ip.Tags, clusterID) {
+		poolType = autoAssignPoolType
+	}
 
 	return fmt.Sprintf("%s-%s", strings.ToLower(network), poolType)
 }
@@ -1,10 +1,14 @@
 package config
 
 import (
+	"fmt"
+	"net/netip"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/metal-stack/metal-go/api/models"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 func Test_addressPool_appendIP(t *testing.T) {
@@ -60,7 +64,7 @@ func Test_addressPool_appendIP(t *testing.T) {
 				return
 			}
 
-			if diff := cmp.Diff(tt.existing, tt.want); diff != "" {
+			if diff := cmp.Diff(tt.existing, tt.want, cmpopts.IgnoreUnexported(addressPool{})); diff != "" {
 				t.Errorf("diff = %v", diff)
 			}
 		})
@@ -129,6 +133,24 @@ func Test_addressPools_addPoolIP(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:     "append new auto-assign pool",
+			poolName: "my-pool-auto-assign",
+			ip: &models.V1IPResponse{
+				Ipaddress: new("192.168.2.1"),
+				Type:      new(models.V1IPResponseTypeEphemeral),
+				Tags:      []string{"cluster.metal-stack.io/id/auto-assign=this-cluster"},
+			},
+			existing: addressPools{},
+			want: addressPools{
+				"my-pool-auto-assign": addressPool{
+					Name:       "my-pool-auto-assign",
+					Protocol:   bgpProtocol,
+					AutoAssign: new(true),
+					CIDRs:      []string{"192.168.2.1/32"},
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -138,9 +160,155 @@ func Test_addressPools_addPoolIP(t *testing.T) {
 				return
 			}
 
-			if diff := cmp.Diff(tt.existing, tt.want); diff != "" {
+			if diff := cmp.Diff(tt.existing, tt.want, cmpopts.IgnoreUnexported(addressPool{})); diff != "" {
 				t.Errorf("diff = %v", diff)
 			}
 		})
 	}
 }
+
+func Test_getPoolName(t *testing.T) {
+	tests := []struct {
+		name    string
+		network string
+		ip      *models.V1IPResponse
+		want    string
+	}{
+		{
+			name:    "ephemeral ip",
+			network: "Internet",
+			ip:      &models.V1IPResponse{Type: new(models.V1IPResponseTypeEphemeral)},
+			want:    "internet-ephemeral",
+		},
+		{
+			name:    "static ip",
+			network: "internet",
+			ip:      &models.V1IPResponse{Type: new(models.V1IPResponseTypeStatic)},
+			want:    "internet-static",
+		},
+		{
+			name:    "auto-assign ip",
+			network: "internet",
+			ip: &models.V1IPResponse{
+				Type: new(models.V1IPResponseTypeEphemeral),
+				Tags: []string{"cluster.metal-stack.io/id/auto-assign=this-cluster", "cluster.metal-stack.io/id/namespace/service=this-cluster/default/svc"},
+			},
+			want: "internet-auto-assign",
+		},
+		{
+			name:    "auto-assign ip of another cluster",
+			network: "internet",
+			ip: &models.V1IPResponse{
+				Type: new(models.V1IPResponseTypeEphemeral),
+				Tags: []string{"cluster.metal-stack.io/id/auto-assign=other-cluster"},
+			},
+			want: "internet-ephemeral",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getPoolName(tt.network, "this-cluster", tt.ip); got != tt.want {
+				t.Errorf("getPoolName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_computeAddressPools_aggregate(t *testing.T) {
+	ip := func(addr string) *models.V1IPResponse {
+		return &models.V1IPResponse{
+			Ipaddress: new(addr),
+			Networkid: new("internet"),
+			Type:      new(models.V1IPResponseTypeEphemeral),
+		}
+	}
+
+	tests := []struct {
+		name string
+		ips  []*models.V1IPResponse
+		want []string
+	}{
+		{
+			name: "single address",
+			ips:  []*models.V1IPResponse{ip("84.1.1.1")},
+			want: []string{"84.1.1.1/32"},
+		},
+		{
+			name: "aligned block",
+			ips:  []*models.V1IPResponse{ip("84.1.1.3"), ip("84.1.1.0"), ip("84.1.1.2"), ip("84.1.1.1")},
+			want: []string{"84.1.1.0/30"},
+		},
+		{
+			name: "unaligned contiguous range",
+			ips:  []*models.V1IPResponse{ip("84.1.1.1"), ip("84.1.1.2"), ip("84.1.1.3"), ip("84.1.1.4"), ip("84.1.1.5")},
+			want: []string{"84.1.1.1/32", "84.1.1.2/31", "84.1.1.4/31"},
+		},
+		{
+			name: "gaps and duplicates",
+			ips:  []*models.V1IPResponse{ip("84.1.1.1"), ip("84.1.1.1"), ip("84.1.1.3"), ip("84.1.2.0"), ip("84.1.2.1")},
+			want: []string{"84.1.1.1/32", "84.1.1.3/32", "84.1.2.0/31"},
+		},
+		{
+			name: "mixed address families",
+			ips:  []*models.V1IPResponse{ip("2001::3"), ip("84.1.1.255"), ip("2001::2"), ip("84.1.2.0")},
+			want: []string{"84.1.1.255/32", "84.1.2.0/32", "2001::2/127"},
+		},
+		{
+			name: "end of address space",
+			ips:  []*models.V1IPResponse{ip("255.255.255.254"), ip("255.255.255.255")},
+			want: []string{"255.255.255.254/31"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pools, err := computeAddressPools(tt.ips, sets.New("internet"), "this-cluster", true)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if diff := cmp.Diff(pools["internet-ephemeral"].CIDRs, tt.want); diff != "" {
+				t.Errorf("diff = %v", diff)
+			}
+		})
+	}
+}
+
+func benchmarkIPs(count int) []*models.V1IPResponse {
+	var (
+		ips  []*models.V1IPResponse
+		addr = netip.MustParseAddr("10.0.0.0")
+	)
+
+	for i := range count {
+		// leave a gap every now and then to make aggregation more realistic
+		if i%100 == 0 {
+			addr = addr.Next()
+		}
+		ips = append(ips, &models.V1IPResponse{
+			Ipaddress: new(addr.String()),
+			Networkid: new("internet"),
+			Type:      new(models.V1IPResponseTypeEphemeral),
+		})
+		addr = addr.Next()
+	}
+
+	return ips
+}
+
+func Benchmark_computeAddressPools(b *testing.B) {
+	for _, count := range []int{100, 1000, 10000} {
+		ips := benchmarkIPs(count)
+
+		for _, aggregate := range []bool{false, true} {
+			b.Run(fmt.Sprintf("ips=%d/aggregate=%t", count, aggregate), func(b *testing.B) {
+				for b.Loop() {
+					_, err := computeAddressPools(ips, sets.New("internet"), "this-cluster", aggregate)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
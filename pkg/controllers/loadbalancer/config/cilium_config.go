@@ -3,8 +3,10 @@ package config
 import (
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
+	"github.com/metal-stack/metal-ccm/pkg/metrics"
 	"github.com/metal-stack/metal-ccm/pkg/resources/kubernetes"
 
 	slimv1 "github.com/cilium/cilium/pkg/k8s/slim/k8s/apis/meta/v1"
@@ -52,6 +54,21 @@ func (c *ciliumConfig) WriteCRs(ctx context.Context) error {
 	return nil
 }
 
+// WritePeers writes the bgp peering policies and the virtual router annotations of the nodes.
+func (c *ciliumConfig) WritePeers(ctx context.Context) error {
+	err := c.writeCiliumBGPPeeringPolicies(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to write ciliumbgppeeringpolicy resources %w", err)
+	}
+
+	err = c.writeNodeAnnotations(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to write node annotations %w", err)
+	}
+
+	return nil
+}
+
 func (c *ciliumConfig) writeCiliumBGPPeeringPolicies(ctx context.Context) error {
 	existingPolicies := ciliumv2alpha1.CiliumBGPPeeringPolicyList{}
 	err := c.client.List(ctx, &existingPolicies)
@@ -74,56 +91,106 @@ func (c *ciliumConfig) writeCiliumBGPPeeringPolicies(ctx context.Context) error
 			if err != nil {
 				return err
 			}
+			metrics.ObserveObjectChanged(string(LoadBalancerTypeCilium), "CiliumBGPPeeringPolicy", "deleted")
 		}
 	}
 
 	for _, peer := range c.base.Peers {
-		bgpPeeringPolicy := &ciliumv2alpha1.CiliumBGPPeeringPolicy{
-			TypeMeta: metav1.TypeMeta{
-				APIVersion: ciliumv2alpha1.CustomResourceDefinitionGroup + "/" + ciliumv2alpha1.CustomResourceDefinitionVersion,
-				Kind:       ciliumv2alpha1.BGPPKindDefinition,
-			},
-			ObjectMeta: metav1.ObjectMeta{
-				Name: fmt.Sprintf("%d", peer.ASN),
-			},
+		err := c.writeCiliumBGPPeeringPolicy(ctx, peer)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteNodePeer writes the bgp peering policy and the virtual router annotation of the given node and deletes the policy of the node if it has no peer,
+// the policies of other nodes are left untouched.
+func (c *ciliumConfig) WriteNodePeer(ctx context.Context, nodeName string) error {
+	existingPolicies := ciliumv2alpha1.CiliumBGPPeeringPolicyList{}
+	err := c.client.List(ctx, &existingPolicies)
+	if err != nil {
+		return fmt.Errorf("failed to write ciliumbgppeeringpolicy resources %w", err)
+	}
+
+	for _, existingPolicy := range existingPolicies.Items {
+		if existingPolicy.Spec.NodeSelector == nil || !selectsOnlyNode(convertSlimMatchExpressions(existingPolicy.Spec.NodeSelector.MatchExpressions), nodeName) {
+			continue
+		}
+		if slices.ContainsFunc(c.base.Peers, func(p *peer) bool { return fmt.Sprintf("%d", p.ASN) == existingPolicy.Name }) {
+			continue
+		}
+
+		err := c.client.Delete(ctx, &existingPolicy)
+		if err != nil {
+			return fmt.Errorf("failed to write ciliumbgppeeringpolicy resources %w", err)
+		}
+		metrics.ObserveObjectChanged(string(LoadBalancerTypeCilium), "CiliumBGPPeeringPolicy", "deleted")
+	}
+
+	for _, peer := range c.base.Peers {
+		err := c.writeCiliumBGPPeeringPolicy(ctx, peer)
+		if err != nil {
+			return fmt.Errorf("failed to write ciliumbgppeeringpolicy resources %w", err)
 		}
 
-		res, err := controllerutil.CreateOrUpdate(ctx, c.client, bgpPeeringPolicy, func() error {
-			bgpPeeringPolicy.Spec = ciliumv2alpha1.CiliumBGPPeeringPolicySpec{
-				NodeSelector: convertNodeSelector(&peer.NodeSelector),
-				VirtualRouters: []ciliumv2alpha1.CiliumBGPVirtualRouter{
-					{
-						LocalASN:      int64(peer.MyASN),
-						ExportPodCIDR: new(true),
-						Neighbors: []ciliumv2alpha1.CiliumBGPNeighbor{
+		err = c.writeNodeAnnotation(ctx, nodeName, int64(peer.ASN))
+		if err != nil {
+			return fmt.Errorf("failed to write node annotations %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c *ciliumConfig) writeCiliumBGPPeeringPolicy(ctx context.Context, peer *peer) error {
+	bgpPeeringPolicy := &ciliumv2alpha1.CiliumBGPPeeringPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: ciliumv2alpha1.CustomResourceDefinitionGroup + "/" + ciliumv2alpha1.CustomResourceDefinitionVersion,
+			Kind:       ciliumv2alpha1.BGPPKindDefinition,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fmt.Sprintf("%d", peer.ASN),
+		},
+	}
+
+	res, err := controllerutil.CreateOrUpdate(ctx, c.client, bgpPeeringPolicy, func() error {
+		bgpPeeringPolicy.Spec = ciliumv2alpha1.CiliumBGPPeeringPolicySpec{
+			NodeSelector: convertNodeSelector(&peer.NodeSelector),
+			VirtualRouters: []ciliumv2alpha1.CiliumBGPVirtualRouter{
+				{
+					LocalASN:      int64(peer.MyASN),
+					ExportPodCIDR: new(true),
+					Neighbors: []ciliumv2alpha1.CiliumBGPNeighbor{
+						{
+							PeerAddress:     "127.0.0.1/32",
+							PeerASN:         int64(peer.ASN),
+							GracefulRestart: &ciliumv2alpha1.CiliumBGPNeighborGracefulRestart{Enabled: true},
+						},
+					},
+					// A NotIn match expression with a dummy key and value have to be used to announce ALL services.
+					ServiceSelector: new(slimv1.LabelSelector{
+						MatchExpressions: []slimv1.LabelSelectorRequirement{
 							{
-								PeerAddress:     "127.0.0.1/32",
-								PeerASN:         int64(peer.ASN),
-								GracefulRestart: &ciliumv2alpha1.CiliumBGPNeighborGracefulRestart{Enabled: true},
+								Key:      ciliumv2alpha1.BGPLoadBalancerClass,
+								Operator: slimv1.LabelSelectorOpNotIn,
+								Values:   []string{"ignore"},
 							},
 						},
-						// A NotIn match expression with a dummy key and value have to be used to announce ALL services.
-						ServiceSelector: new(slimv1.LabelSelector{
-							MatchExpressions: []slimv1.LabelSelectorRequirement{
-								{
-									Key:      ciliumv2alpha1.BGPLoadBalancerClass,
-									Operator: slimv1.LabelSelectorOpNotIn,
-									Values:   []string{"ignore"},
-								},
-							},
-						}),
-					},
+					}),
 				},
-			}
-			return nil
-		})
-		if err != nil {
-			return err
+			},
 		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		if res != controllerutil.OperationResultNone {
-			klog.Infof("bgppeer: %v", res)
-		}
+	if res != controllerutil.OperationResultNone {
+		klog.Infof("bgppeer: %v", res)
+		metrics.ObserveObjectChanged(string(LoadBalancerTypeCilium), "CiliumBGPPeeringPolicy", string(res))
 	}
 
 	return nil
@@ -151,6 +218,7 @@ func (c *ciliumConfig) writeCiliumLoadBalancerIPPools(ctx context.Context) error
 			if err != nil {
 				return err
 			}
+			metrics.ObserveObjectChanged(string(LoadBalancerTypeCilium), "CiliumLoadBalancerIPPool", "deleted")
 		}
 	}
 
@@ -184,6 +252,7 @@ func (c *ciliumConfig) writeCiliumLoadBalancerIPPools(ctx context.Context) error
 
 		if res != controllerutil.OperationResultNone {
 			klog.Infof("ipaddresspool: %v", res)
+			metrics.ObserveObjectChanged(string(LoadBalancerTypeCilium), "CiliumLoadBalancerIPPool", string(res))
 		}
 	}
 
@@ -196,31 +265,40 @@ func (c *ciliumConfig) writeNodeAnnotations(ctx context.Context) error {
 		return fmt.Errorf("failed to write node annotations: %w", err)
 	}
 
-	backoff := wait.Backoff{
-		Steps:    20,
-		Duration: 50 * time.Millisecond,
-		Jitter:   1.0,
-	}
-
 	for _, n := range nodes {
 		asn, err := getASNFromNodeLabels(n)
 		if err != nil {
 			return fmt.Errorf("failed to write node annotations for node %s: %w", n.Name, err)
 		}
 
-		annotations := map[string]string{
-			fmt.Sprintf("cilium.io/bgp-virtual-router.%d", asn): "router-id=127.0.0.1",
-		}
-
-		err = kubernetes.UpdateNodeAnnotationsWithBackoff(ctx, c.k8sClient, n.Name, annotations, backoff)
+		err = c.writeNodeAnnotation(ctx, n.Name, asn)
 		if err != nil {
-			return fmt.Errorf("failed to write node annotations for node %s: %w", n.Name, err)
+			return err
 		}
 	}
 
 	return nil
 }
 
+func (c *ciliumConfig) writeNodeAnnotation(ctx context.Context, nodeName string, asn int64) error {
+	backoff := wait.Backoff{
+		Steps:    20,
+		Duration: 50 * time.Millisecond,
+		Jitter:   1.0,
+	}
+
+	annotations := map[string]string{
+		fmt.Sprintf("cilium.io/bgp-virtual-router.%d", asn): "router-id=127.0.0.1",
+	}
+
+	err := kubernetes.UpdateNodeAnnotationsWithBackoff(ctx, c.k8sClient, nodeName, annotations, backoff)
+	if err != nil {
+		return fmt.Errorf("failed to write node annotations for node %s: %w", nodeName, err)
+	}
+
+	return nil
+}
+
 func convertNodeSelector(s *metav1.LabelSelector) *slimv1.LabelSelector {
 	var machExpressions []slimv1.LabelSelectorRequirement
 	for _, me := range s.MatchExpressions {
@@ -234,3 +312,15 @@ func convertNodeSelector(s *metav1.LabelSelector) *slimv1.LabelSelector {
 		MatchExpressions: machExpressions,
 	}
 }
+
+func convertSlimMatchExpressions(exprs []slimv1.LabelSelectorRequirement) []metav1.LabelSelectorRequirement {
+	var matchExpressions []metav1.LabelSelectorRequirement
+	for _, me := range exprs {
+		matchExpressions = append(matchExpressions, metav1.LabelSelectorRequirement{
+			Key:      me.Key,
+			Operator: metav1.LabelSelectorOperator(me.Operator),
+			Values:   me.Values,
+		})
+	}
+	return matchExpressions
+}
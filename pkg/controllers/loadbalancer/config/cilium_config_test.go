@@ -296,13 +296,13 @@ func TestCiliumConfig(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cfg, err := New(LoadBalancerTypeCilium, tt.ips, tt.nws, tt.nodes, nil, nil)
+			cfg, err := New(LoadBalancerTypeCilium, tt.ips, tt.nws, "this-cluster", false, tt.nodes, nil, nil)
 			if diff := cmp.Diff(err, tt.wantErr); diff != "" {
 				t.Errorf("error = %v", diff)
 				return
 			}
 
-			if diff := cmp.Diff(cfg, tt.want, cmpopts.IgnoreUnexported(ciliumConfig{})); diff != "" {
+			if diff := cmp.Diff(cfg, tt.want, cmpopts.IgnoreUnexported(ciliumConfig{}, addressPool{})); diff != "" {
 				t.Errorf("diff = %v", diff)
 			}
 		})
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/metal-stack/metal-ccm/pkg/resources/kubernetes"
+	"github.com/metal-stack/metal-ccm/pkg/tags"
 	"github.com/metal-stack/metal-go/api/models"
 	"github.com/metal-stack/metal-lib/pkg/pointer"
 	"github.com/metal-stack/metal-lib/pkg/tag"
@@ -30,20 +32,47 @@ func LoadBalancerTypeFromString(lb string) (LoadBalancerType, error) {
 }
 
 type LoadBalancerConfig interface {
+	PeerConfig
 	WriteCRs(ctx context.Context) error
 }
 
+// PeerConfig only knows the bgp peers of the nodes, it is used to update the peers without computing the address pools.
+type PeerConfig interface {
+	WritePeers(ctx context.Context) error
+	// WriteNodePeer only writes the bgp peer of the given node, its peer is deleted if the node has none.
+	WriteNodePeer(ctx context.Context, nodeName string) error
+}
+
 type baseConfig struct {
 	Peers        []*peer
 	AddressPools addressPools
 }
 
-func New(loadBalancerType LoadBalancerType, ips []*models.V1IPResponse, nws sets.Set[string], nodes []v1.Node, c client.Client, k8sClientSet clientset.Interface) (LoadBalancerConfig, error) {
-	bc, err := newBaseConfig(ips, nws, nodes)
+func New(loadBalancerType LoadBalancerType, ips []*models.V1IPResponse, nws sets.Set[string], clusterID string, aggregateAddresses bool, nodes []v1.Node, c client.Client, k8sClientSet clientset.Interface) (LoadBalancerConfig, error) {
+	bc, err := newBaseConfig(ips, nws, clusterID, aggregateAddresses, nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch loadBalancerType {
+	case LoadBalancerTypeMetalLB:
+		return newMetalLBConfig(bc, c), nil
+	case LoadBalancerTypeCilium:
+		return newCiliumConfig(bc, c, k8sClientSet), nil
+	default:
+		return nil, fmt.Errorf("unknown load balancer type: %s", loadBalancerType)
+	}
+}
+
+// NewPeers returns a config which only writes the bgp peers for the given nodes.
+func NewPeers(loadBalancerType LoadBalancerType, nodes []v1.Node, c client.Client, k8sClientSet clientset.Interface) (PeerConfig, error) {
+	peers, err := computePeers(nodes)
 	if err != nil {
 		return nil, err
 	}
 
+	bc := &baseConfig{Peers: peers}
+
 	switch loadBalancerType {
 	case LoadBalancerTypeMetalLB:
 		return newMetalLBConfig(bc, c), nil
@@ -54,8 +83,8 @@ func New(loadBalancerType LoadBalancerType, ips []*models.V1IPResponse, nws sets
 	}
 }
 
-func newBaseConfig(ips []*models.V1IPResponse, nws sets.Set[string], nodes []v1.Node) (*baseConfig, error) {
-	pools, err := computeAddressPools(ips, nws)
+func newBaseConfig(ips []*models.V1IPResponse, nws sets.Set[string], clusterID string, aggregateAddresses bool, nodes []v1.Node) (*baseConfig, error) {
+	pools, err := computeAddressPools(ips, nws, clusterID, aggregateAddresses)
 	if err != nil {
 		return nil, err
 	}
@@ -71,7 +100,10 @@ func newBaseConfig(ips []*models.V1IPResponse, nws sets.Set[string], nodes []v1.
 	}, nil
 }
 
-func computeAddressPools(ips []*models.V1IPResponse, nws sets.Set[string]) (addressPools, error) {
+// computeAddressPools groups the given ips into address pools per network and ip type.
+// ips of the auto-assign pools of other clusters are skipped.
+// if aggregateAddresses is set, contiguous addresses of a pool are merged into the smallest covering prefixes.
+func computeAddressPools(ips []*models.V1IPResponse, nws sets.Set[string], clusterID string, aggregateAddresses bool) (addressPools, error) {
 	var (
 		pools = addressPools{}
 		errs  []error
@@ -86,10 +118,14 @@ func computeAddressPools(ips []*models.V1IPResponse, nws sets.Set[string]) (addr
 			klog.Infof("skipping ip %q: not part of cluster networks", *ip.Ipaddress)
 			continue
 		}
+		if tags.IsAutoAssignOfOtherCluster(ip.Tags, clusterID) {
+			klog.Infof("skipping ip %q: part of the auto-assign pool of another cluster", *ip.Ipaddress)
+			continue
+		}
 
 		var (
 			net      = *ip.Networkid
-			poolName = getPoolName(net, ip)
+			poolName = getPoolName(net, clusterID, ip)
 		)
 
 		err := pools.addPoolIP(poolName, ip)
@@ -102,13 +138,27 @@ func computeAddressPools(ips []*models.V1IPResponse, nws sets.Set[string]) (addr
 		return nil, errors.Join(errs...)
 	}
 
+	if aggregateAddresses {
+		for name, pool := range pools {
+			pool.aggregate()
+			pools[name] = pool
+		}
+	}
+
 	return pools, nil
 }
 
+// computePeers returns a peer for every node, except for nodes which are excluded from load balancers.
+// Nodes which are not ready keep their peer, such that a short outage of the kubelet does not withdraw the routes of the node.
 func computePeers(nodes []v1.Node) ([]*peer, error) {
 	var peers []*peer
 
 	for _, n := range nodes {
+		if kubernetes.NodeExcludedFromLoadBalancers(n) {
+			klog.Infof("skipping peer for node %q, it is excluded from load balancers", n.Name)
+			continue
+		}
+
 		asn, err := getASNFromNodeLabels(n)
 		if err != nil {
 			return nil, err
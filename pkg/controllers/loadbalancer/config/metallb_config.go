@@ -3,11 +3,14 @@ package config
 import (
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog/v2"
 
+	"github.com/metal-stack/metal-ccm/pkg/metrics"
+
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
@@ -31,60 +34,10 @@ func newMetalLBConfig(base *baseConfig, c client.Client) *metalLBConfig {
 }
 
 func (m *metalLBConfig) WriteCRs(ctx context.Context) error {
-	bgpPeerList := metallbv1beta2.BGPPeerList{}
-	err := m.client.List(ctx, &bgpPeerList, client.InNamespace(metallbNamespace))
+	err := m.WritePeers(ctx)
 	if err != nil {
 		return err
 	}
-	for _, existingPeer := range bgpPeerList.Items {
-		found := false
-
-		for _, peer := range m.Base.Peers {
-			if fmt.Sprintf("peer-%d", peer.ASN) == existingPeer.Name {
-				found = true
-				break
-			}
-		}
-
-		if !found {
-			err := m.client.Delete(ctx, &existingPeer)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	for _, peer := range m.Base.Peers {
-		bgpPeer := &metallbv1beta2.BGPPeer{
-			TypeMeta: metav1.TypeMeta{
-				APIVersion: "metallb.io/v1beta2",
-				Kind:       "BGPPeer",
-			},
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      fmt.Sprintf("peer-%d", peer.ASN),
-				Namespace: metallbNamespace,
-			},
-		}
-
-		res, err := controllerutil.CreateOrUpdate(ctx, m.client, bgpPeer, func() error {
-			bgpPeer.Spec = metallbv1beta2.BGPPeerSpec{
-				MyASN:         peer.MyASN,
-				ASN:           peer.ASN,
-				HoldTime:      new(metav1.Duration{Duration: 90 * time.Second}),
-				KeepaliveTime: new(metav1.Duration{Duration: 0 * time.Second}),
-				Address:       peer.Address,
-				NodeSelectors: []metav1.LabelSelector{peer.NodeSelector},
-			}
-			return nil
-		})
-		if err != nil {
-			return err
-		}
-
-		if res != controllerutil.OperationResultNone {
-			klog.Infof("bgppeer: %v", res)
-		}
-	}
 
 	addressPoolList := metallbv1beta1.IPAddressPoolList{}
 	err = m.client.List(ctx, &addressPoolList, client.InNamespace(metallbNamespace))
@@ -104,6 +57,7 @@ func (m *metalLBConfig) WriteCRs(ctx context.Context) error {
 			if err != nil {
 				return err
 			}
+			metrics.ObserveObjectChanged(string(LoadBalancerTypeMetalLB), "IPAddressPool", "deleted")
 		}
 	}
 
@@ -133,6 +87,7 @@ func (m *metalLBConfig) WriteCRs(ctx context.Context) error {
 
 		if res != controllerutil.OperationResultNone {
 			klog.Infof("ipaddresspool: %v", res)
+			metrics.ObserveObjectChanged(string(LoadBalancerTypeMetalLB), "IPAddressPool", string(res))
 		}
 	}
 
@@ -156,6 +111,7 @@ func (m *metalLBConfig) WriteCRs(ctx context.Context) error {
 				if err != nil {
 					return err
 				}
+				metrics.ObserveObjectChanged(string(LoadBalancerTypeMetalLB), "BGPAdvertisement", "deleted")
 			}
 		}
 
@@ -182,8 +138,112 @@ func (m *metalLBConfig) WriteCRs(ctx context.Context) error {
 
 		if res != controllerutil.OperationResultNone {
 			klog.Infof("bgpadvertisement: %v", res)
+			metrics.ObserveObjectChanged(string(LoadBalancerTypeMetalLB), "BGPAdvertisement", string(res))
+		}
+	}
+
+	return nil
+}
+
+// WritePeers writes the bgp peers and deletes the peers of nodes which are gone.
+func (m *metalLBConfig) WritePeers(ctx context.Context) error {
+	bgpPeerList := metallbv1beta2.BGPPeerList{}
+	err := m.client.List(ctx, &bgpPeerList, client.InNamespace(metallbNamespace))
+	if err != nil {
+		return err
+	}
+	for _, existingPeer := range bgpPeerList.Items {
+		found := false
+
+		for _, peer := range m.Base.Peers {
+			if fmt.Sprintf("peer-%d", peer.ASN) == existingPeer.Name {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			err := m.client.Delete(ctx, &existingPeer)
+			if err != nil {
+				return err
+			}
+			metrics.ObserveObjectChanged(string(LoadBalancerTypeMetalLB), "BGPPeer", "deleted")
 		}
 	}
 
+	for _, peer := range m.Base.Peers {
+		err := m.writeBGPPeer(ctx, peer)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteNodePeer writes the bgp peer of the given node and deletes the peer of the node if it has none, the peers of other nodes are left untouched.
+func (m *metalLBConfig) WriteNodePeer(ctx context.Context, nodeName string) error {
+	bgpPeerList := metallbv1beta2.BGPPeerList{}
+	err := m.client.List(ctx, &bgpPeerList, client.InNamespace(metallbNamespace))
+	if err != nil {
+		return err
+	}
+	for _, existingPeer := range bgpPeerList.Items {
+		if len(existingPeer.Spec.NodeSelectors) != 1 || !selectsOnlyNode(existingPeer.Spec.NodeSelectors[0].MatchExpressions, nodeName) {
+			continue
+		}
+		if slices.ContainsFunc(m.Base.Peers, func(p *peer) bool { return fmt.Sprintf("peer-%d", p.ASN) == existingPeer.Name }) {
+			continue
+		}
+
+		err := m.client.Delete(ctx, &existingPeer)
+		if err != nil {
+			return err
+		}
+		metrics.ObserveObjectChanged(string(LoadBalancerTypeMetalLB), "BGPPeer", "deleted")
+	}
+
+	for _, peer := range m.Base.Peers {
+		err := m.writeBGPPeer(ctx, peer)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *metalLBConfig) writeBGPPeer(ctx context.Context, peer *peer) error {
+	bgpPeer := &metallbv1beta2.BGPPeer{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "metallb.io/v1beta2",
+			Kind:       "BGPPeer",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("peer-%d", peer.ASN),
+			Namespace: metallbNamespace,
+		},
+	}
+
+	res, err := controllerutil.CreateOrUpdate(ctx, m.client, bgpPeer, func() error {
+		bgpPeer.Spec = metallbv1beta2.BGPPeerSpec{
+			MyASN:         peer.MyASN,
+			ASN:           peer.ASN,
+			HoldTime:      new(metav1.Duration{Duration: 90 * time.Second}),
+			KeepaliveTime: new(metav1.Duration{Duration: 0 * time.Second}),
+			Address:       peer.Address,
+			NodeSelectors: []metav1.LabelSelector{peer.NodeSelector},
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if res != controllerutil.OperationResultNone {
+		klog.Infof("bgppeer: %v", res)
+		metrics.ObserveObjectChanged(string(LoadBalancerTypeMetalLB), "BGPPeer", string(res))
+	}
+
 	return nil
 }
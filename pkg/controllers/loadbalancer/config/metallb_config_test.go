@@ -507,7 +507,7 @@ func TestMetalLBConfig(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cfg, err := New(LoadBalancerTypeMetalLB, tt.ips, tt.nws, tt.nodes, nil, nil)
+			cfg, err := New(LoadBalancerTypeMetalLB, tt.ips, tt.nws, "this-cluster", false, tt.nodes, nil, nil)
 			if err != nil {
 				if diff := cmp.Diff(err.Error(), *tt.wantErrmessage); diff != "" {
 					t.Errorf("error = %v", diff)
@@ -515,7 +515,7 @@ func TestMetalLBConfig(t *testing.T) {
 				return
 			}
 
-			if diff := cmp.Diff(cfg, tt.want, cmpopts.IgnoreUnexported(metalLBConfig{})); diff != "" {
+			if diff := cmp.Diff(cfg, tt.want, cmpopts.IgnoreUnexported(metalLBConfig{}, addressPool{})); diff != "" {
 				t.Errorf("diff = %v", diff)
 			}
 		})
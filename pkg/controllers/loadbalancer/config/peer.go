@@ -1,6 +1,8 @@
 package config
 
 import (
+	"slices"
+
 	"github.com/metal-stack/metal-ccm/pkg/resources/kubernetes"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -17,8 +19,8 @@ func newPeer(node v1.Node, asn int64) (*peer, error) {
 	hostname := node.GetName()
 
 	matchExpression := metav1.LabelSelectorRequirement{
-		Key:      "kubernetes.io/hostname",
-		Operator: "In",
+		Key:      v1.LabelHostname,
+		Operator: metav1.LabelSelectorOpIn,
 		Values: []string{
 			hostname,
 		},
@@ -42,3 +44,11 @@ func newPeer(node v1.Node, asn int64) (*peer, error) {
 		},
 	}, nil
 }
+
+// selectsOnlyNode returns true if the given match expressions of the node selector of a peer select exactly the given node.
+func selectsOnlyNode(exprs []metav1.LabelSelectorRequirement, nodeName string) bool {
+	return len(exprs) == 1 &&
+		exprs[0].Key == v1.LabelHostname &&
+		exprs[0].Operator == metav1.LabelSelectorOpIn &&
+		slices.Equal(exprs[0].Values, []string{nodeName})
+}
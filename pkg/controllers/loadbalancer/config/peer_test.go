@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/metal-stack/metal-lib/pkg/tag"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_computePeers(t *testing.T) {
+	node := func(name string, mutate func(n *v1.Node)) v1.Node {
+		n := v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{tag.MachineNetworkPrimaryASN: "4200000001"},
+			},
+			Status: v1.NodeStatus{
+				Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.1"}},
+			},
+		}
+		if mutate != nil {
+			mutate(&n)
+		}
+		return n
+	}
+
+	nodes := []v1.Node{
+		node("without-conditions", nil),
+		node("ready", func(n *v1.Node) {
+			n.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}
+		}),
+		node("not-ready", func(n *v1.Node) {
+			n.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionFalse}}
+		}),
+		node("excluded", func(n *v1.Node) {
+			n.Labels[v1.LabelNodeExcludeBalancers] = "true"
+		}),
+	}
+
+	peers, err := computePeers(nodes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []string
+	for _, p := range peers {
+		got = append(got, p.NodeSelector.MatchExpressions[0].Values...)
+	}
+	if diff := cmp.Diff([]string{"without-conditions", "ready", "not-ready"}, got); diff != "" {
+		t.Errorf("diff = %v", diff)
+	}
+}
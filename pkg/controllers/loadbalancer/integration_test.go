@@ -0,0 +1,418 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/metal-stack/metal-go/api/models"
+	"github.com/metal-stack/metal-lib/pkg/tag"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	cloudproviderapi "k8s.io/cloud-provider/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/metal-stack/metal-ccm/pkg/cloudconfig"
+	"github.com/metal-stack/metal-ccm/pkg/controllers/loadbalancer/config"
+	"github.com/metal-stack/metal-ccm/pkg/health"
+	"github.com/metal-stack/metal-ccm/pkg/resources/constants"
+	"github.com/metal-stack/metal-ccm/pkg/resources/metal"
+	"github.com/metal-stack/metal-ccm/pkg/resources/metal/fake"
+	"github.com/metal-stack/metal-ccm/pkg/tags"
+)
+
+const (
+	testProject = "project-a"
+	testCluster = "cluster-a"
+	testNetwork = "internet"
+)
+
+func newTestController(t *testing.T, api *fake.MetalAPI, objects ...runtime.Object) *LoadBalancerController {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := metallbv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := metallbv1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	clientSet := k8sfake.NewSimpleClientset(objects...)
+
+	l := New(&cloudconfig.CloudConfig{
+		ProjectID: testProject,
+		ClusterID: testCluster,
+		Networks: cloudconfig.Networks{
+			DefaultExternalNetworkID: testNetwork,
+			AdditionalNetworks:       []string{testNetwork},
+		},
+		LoadBalancer: cloudconfig.LoadBalancer{
+			Type: config.LoadBalancerTypeMetalLB,
+		},
+	})
+	l.MetalService = metal.New(metal.NewMetalGoBackend(api.Client()), clientSet, testProject, health.NewMetalAPI(3), nil)
+	l.K8sClientSet = clientSet
+	l.K8sClient = crfake.NewClientBuilder().WithScheme(scheme).Build()
+	l.configDebounce = 0
+
+	return l
+}
+
+func testNode() *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node-a",
+			Labels: map[string]string{tag.MachineNetworkPrimaryASN: "4200000001"},
+		},
+		Status: v1.NodeStatus{
+			Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.1"}},
+		},
+	}
+}
+
+func testService(name string) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+	}
+}
+
+func TestLoadBalancerController_EnsureLoadBalancer(t *testing.T) {
+	ctx := context.Background()
+
+	api := fake.New()
+	api.AddNetwork(testNetwork, "185.1.2.0/24")
+
+	node := testNode()
+	svc := testService("web")
+	l := newTestController(t, api, node, svc)
+
+	status, err := l.EnsureLoadBalancer(ctx, "", svc, []*v1.Node{node})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]v1.LoadBalancerIngress{{IP: "185.1.2.1"}}, status.Ingress); diff != "" {
+		t.Errorf("diff = %v", diff)
+	}
+
+	ip, ok := api.IP("185.1.2.1")
+	if !ok {
+		t.Fatal("expected ip to be allocated")
+	}
+	serviceTag := tags.BuildClusterServiceFQNTag(testCluster, "default", "web")
+	if diff := cmp.Diff([]string{serviceTag}, ip.Tags); diff != "" {
+		t.Errorf("diff = %v", diff)
+	}
+
+	updated, err := l.K8sClientSet.CoreV1().Services("default").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Spec.LoadBalancerIP != "185.1.2.1" {
+		t.Errorf("service load balancer ip = %q, want %q", updated.Spec.LoadBalancerIP, "185.1.2.1")
+	}
+
+	if l.configQueue.Len() != 1 {
+		t.Fatalf("expected a config update to be enqueued, got %d", l.configQueue.Len())
+	}
+	l.processNextConfigUpdate(ctx)
+
+	var pools metallbv1beta1.IPAddressPoolList
+	if err := l.K8sClient.List(ctx, &pools, client.InNamespace("metallb-system")); err != nil {
+		t.Fatal(err)
+	}
+	if len(pools.Items) != 1 {
+		t.Errorf("expected one address pool, got %d", len(pools.Items))
+	}
+	var peers metallbv1beta2.BGPPeerList
+	if err := l.K8sClient.List(ctx, &peers, client.InNamespace("metallb-system")); err != nil {
+		t.Fatal(err)
+	}
+	if len(peers.Items) != 1 {
+		t.Errorf("expected one bgp peer, got %d", len(peers.Items))
+	}
+
+	err = l.EnsureLoadBalancerDeleted(ctx, "", svc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := api.IP("185.1.2.1"); ok {
+		t.Error("expected ephemeral ip to be freed")
+	}
+}
+
+func TestLoadBalancerController_EnsureLoadBalancerWithMultipleNetworks(t *testing.T) {
+	ctx := t.Context()
+
+	api := fake.New()
+	api.AddNetwork(testNetwork, "185.1.2.0/24")
+	api.AddNetwork("partner", "10.1.2.0/24")
+
+	node := testNode()
+	svc := testService("web")
+	svc.Annotations = map[string]string{constants.MetalNetworksAnnotation: testNetwork + ",partner"}
+	l := newTestController(t, api, node, svc)
+	l.additionalNetworks = sets.New(testNetwork, "partner")
+
+	want := []v1.LoadBalancerIngress{{IP: "185.1.2.1"}, {IP: "10.1.2.1"}}
+
+	status, err := l.EnsureLoadBalancer(ctx, "", svc, []*v1.Node{node})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(want, status.Ingress); diff != "" {
+		t.Errorf("diff = %v", diff)
+	}
+
+	// the service is synced again before its status was written, no further ips must be acquired
+	updated, err := l.K8sClientSet.CoreV1().Services("default").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err = l.EnsureLoadBalancer(ctx, "", updated, []*v1.Node{node})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(want, status.Ingress); diff != "" {
+		t.Errorf("diff = %v", diff)
+	}
+
+	serviceTag := tags.BuildClusterServiceFQNTag(testCluster, "default", "web")
+	for _, addr := range []string{"185.1.2.1", "10.1.2.1"} {
+		ip, ok := api.IP(addr)
+		if !ok {
+			t.Fatalf("expected ip %s to be allocated", addr)
+		}
+		if diff := cmp.Diff([]string{serviceTag}, ip.Tags); diff != "" {
+			t.Errorf("diff = %v", diff)
+		}
+	}
+	for _, addr := range []string{"185.1.2.2", "10.1.2.2"} {
+		if _, ok := api.IP(addr); ok {
+			t.Errorf("expected no further ip to be acquired, got %s", addr)
+		}
+	}
+
+	err = l.EnsureLoadBalancerDeleted(ctx, "", updated)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, addr := range []string{"185.1.2.1", "10.1.2.1"} {
+		if _, ok := api.IP(addr); ok {
+			t.Errorf("expected ephemeral ip %s to be freed", addr)
+		}
+	}
+}
+
+func TestLoadBalancerController_EnsureLoadBalancerWithFixedIP(t *testing.T) {
+	ctx := context.Background()
+
+	otherServiceTag := tags.BuildClusterServiceFQNTag(testCluster, "default", "other")
+	api := fake.New()
+	api.AddNetwork(testNetwork, "185.1.2.0/24")
+	api.AddIP(&models.V1IPResponse{
+		Ipaddress: new("185.1.2.10"),
+		Networkid: new(testNetwork),
+		Projectid: new(testProject),
+		Type:      new(models.V1IPBaseTypeStatic),
+		Tags:      []string{otherServiceTag},
+	})
+
+	node := testNode()
+	svc := testService("web")
+	svc.Spec.LoadBalancerIP = "185.1.2.10"
+	l := newTestController(t, api, node, svc)
+
+	status, err := l.EnsureLoadBalancer(ctx, "", svc, []*v1.Node{node})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]v1.LoadBalancerIngress{{IP: "185.1.2.10"}}, status.Ingress); diff != "" {
+		t.Errorf("diff = %v", diff)
+	}
+
+	serviceTag := tags.BuildClusterServiceFQNTag(testCluster, "default", "web")
+	ip, _ := api.IP("185.1.2.10")
+	if !slices.Contains(ip.Tags, serviceTag) || !slices.Contains(ip.Tags, otherServiceTag) {
+		t.Errorf("expected ip to be tagged for both services, got %v", ip.Tags)
+	}
+
+	err = l.EnsureLoadBalancerDeleted(ctx, "", svc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ip, ok := api.IP("185.1.2.10")
+	if !ok {
+		t.Fatal("expected static ip to be kept")
+	}
+	if diff := cmp.Diff([]string{otherServiceTag}, ip.Tags); diff != "" {
+		t.Errorf("diff = %v", diff)
+	}
+}
+
+func TestLoadBalancerController_EnqueueConfigUpdate(t *testing.T) {
+	api := fake.New()
+	api.AddNetwork(testNetwork, "185.1.2.0/24")
+	l := newTestController(t, api, testNode())
+
+	for range 5 {
+		l.EnqueueConfigUpdate()
+	}
+	if l.configQueue.Len() != 1 {
+		t.Errorf("expected config updates to be coalesced, got %d", l.configQueue.Len())
+	}
+
+	l.processNextConfigUpdate(t.Context())
+
+	if l.configQueue.Len() != 0 {
+		t.Errorf("expected config queue to be empty, got %d", l.configQueue.Len())
+	}
+	if l.lastConfigSuccess.IsZero() {
+		t.Error("expected last config success to be recorded")
+	}
+	var peers metallbv1beta2.BGPPeerList
+	if err := l.K8sClient.List(t.Context(), &peers, client.InNamespace("metallb-system")); err != nil {
+		t.Fatal(err)
+	}
+	if len(peers.Items) != 1 {
+		t.Errorf("expected one bgp peer, got %d", len(peers.Items))
+	}
+}
+
+func TestLoadBalancerController_EnqueuePeersUpdate(t *testing.T) {
+	ctx := t.Context()
+
+	api := fake.New()
+	api.AddNetwork(testNetwork, "185.1.2.0/24")
+	nodeB := testNode()
+	nodeB.Name = "node-b"
+	nodeB.Labels[tag.MachineNetworkPrimaryASN] = "4200000002"
+	l := newTestController(t, api, testNode(), nodeB)
+
+	l.EnqueueConfigUpdate()
+	l.processNextConfigUpdate(ctx)
+
+	for _, name := range []string{"node-a", "node-b"} {
+		err := l.K8sClientSet.CoreV1().Nodes().Delete(ctx, name, metav1.DeleteOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// only the peer of the node which was enqueued is reconciled
+	l.EnqueuePeersUpdate("node-a")
+	l.processNextConfigUpdate(ctx)
+
+	var peers metallbv1beta2.BGPPeerList
+	if err := l.K8sClient.List(ctx, &peers, client.InNamespace("metallb-system")); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range peers.Items {
+		got = append(got, p.Name)
+	}
+	if diff := cmp.Diff([]string{"peer-4200000002"}, got); diff != "" {
+		t.Errorf("diff = %v", diff)
+	}
+}
+
+func TestLoadBalancerController_EnqueuePeersUpdateWithoutASN(t *testing.T) {
+	ctx := t.Context()
+
+	node := testNode()
+	delete(node.Labels, tag.MachineNetworkPrimaryASN)
+	l := newTestController(t, fake.New(), node)
+
+	l.EnqueuePeersUpdate("node-a")
+	l.processNextConfigUpdate(ctx)
+
+	if n := l.configQueue.NumRequeues("peers/node-a"); n != 0 {
+		t.Errorf("expected a node without asn not to be retried, got %d requeues", n)
+	}
+	if l.configQueue.Len() != 0 {
+		t.Errorf("expected the peers queue to be empty, got %d", l.configQueue.Len())
+	}
+	var peers metallbv1beta2.BGPPeerList
+	if err := l.K8sClient.List(ctx, &peers, client.InNamespace("metallb-system")); err != nil {
+		t.Fatal(err)
+	}
+	if len(peers.Items) != 0 {
+		t.Errorf("expected no bgp peer for a node without asn, got %d", len(peers.Items))
+	}
+}
+
+func TestLoadBalancerController_ensureAutoAssignPool(t *testing.T) {
+	ctx := t.Context()
+
+	api := fake.New()
+	api.AddNetwork(testNetwork, "185.1.2.0/24")
+	l := newTestController(t, api)
+	l.autoAssignPoolSize = 2
+
+	autoAssignIP := func(addr, clusterID string, extraTags ...string) *models.V1IPResponse {
+		return &models.V1IPResponse{
+			Ipaddress: new(addr),
+			Networkid: new(testNetwork),
+			Projectid: new(testProject),
+			Type:      new(models.V1IPBaseTypeEphemeral),
+			Tags:      append([]string{tags.BuildClusterAutoAssignTag(clusterID)}, extraTags...),
+		}
+	}
+	ips := []*models.V1IPResponse{
+		autoAssignIP("185.1.2.1", testCluster, tags.BuildClusterServiceFQNTag(testCluster, "default", "svc")),
+		autoAssignIP("185.1.2.2", testCluster),
+		autoAssignIP("185.1.2.3", "cluster-b"),
+	}
+
+	got, err := l.ensureAutoAssignPool(ctx, ips)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the ip in use by a service and the ip of the other cluster are not counted, so one more ip is required
+	if len(got) != len(ips)+1 {
+		t.Fatalf("expected one ip to be allocated for the auto-assign pool, got %d ips", len(got))
+	}
+	if allocated := got[len(got)-1]; !tags.IsAutoAssign(allocated.Tags, testCluster) {
+		t.Errorf("expected the allocated ip to be part of the auto-assign pool, got tags %v", allocated.Tags)
+	}
+
+	got, err = l.ensureAutoAssignPool(ctx, got)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != len(ips)+1 {
+		t.Errorf("expected no further ip to be allocated, got %d ips", len(got))
+	}
+}
+
+func TestLoadBalancerController_ensureAutoAssignedIPsWithoutIngress(t *testing.T) {
+	api := fake.New()
+	l := newTestController(t, api)
+	l.autoAssignPoolSize = 2
+
+	status, err := l.ensureAutoAssignedIPs(t.Context(), testService("svc"))
+	if status != nil {
+		t.Errorf("expected no status while the ip is not assigned yet, got: %v", status)
+	}
+	var retryErr *cloudproviderapi.RetryError
+	if !errors.As(err, &retryErr) {
+		t.Fatalf("expected a retry error while the ip is not assigned yet, got: %v", err)
+	}
+	if retryErr.RetryAfter() != autoAssignRetryDelay {
+		t.Errorf("retry after = %v, want %v", retryErr.RetryAfter(), autoAssignRetryDelay)
+	}
+	if l.configQueue.Len() != 1 {
+		t.Errorf("expected a config update to be enqueued for the auto-assign pool, got %d", l.configQueue.Len())
+	}
+}
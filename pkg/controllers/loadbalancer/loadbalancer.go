@@ -6,9 +6,13 @@ import (
 	"slices"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/metal-stack/metal-ccm/pkg/cloudconfig"
 	"github.com/metal-stack/metal-ccm/pkg/controllers/loadbalancer/config"
+	"github.com/metal-stack/metal-ccm/pkg/metrics"
 	"github.com/metal-stack/metal-ccm/pkg/tags"
+	"github.com/metal-stack/metal-ccm/pkg/tracing"
 	"github.com/metal-stack/metal-lib/pkg/pointer"
 	"github.com/metal-stack/metal-lib/pkg/tag"
 
@@ -23,11 +27,14 @@ import (
 	"k8s.io/klog/v2"
 
 	retrygo "github.com/avast/retry-go/v4"
+	"go.opentelemetry.io/otel/attribute"
 	clientset "k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
 	cloudprovider "k8s.io/cloud-provider"
+	cloudproviderapi "k8s.io/cloud-provider/api"
 )
 
 type LoadBalancerController struct {
@@ -39,24 +46,31 @@ type LoadBalancerController struct {
 	additionalNetworks       sets.Set[string]
 	K8sClientSet             clientset.Interface
 	K8sClient                client.Client
-	configWriteMutex         *sync.Mutex
+	configQueue              workqueue.TypedRateLimitingInterface[string]
+	configDebounce           time.Duration
+	lastConfigSuccess        time.Time
 	ipAllocateMutex          *sync.Mutex
 	ipUpdateMutex            *sync.Mutex
 	loadBalancerType         config.LoadBalancerType
+	autoAssignPoolSize       int
+	aggregateAddressPools    bool
 }
 
 // New returns a new load balancer controller that satisfies the kubernetes cloud provider load balancer interface
-func New(partitionID, projectID, clusterID, defaultExternalNetworkID string, additionalNetworks []string, loadBalancerType config.LoadBalancerType) *LoadBalancerController {
+func New(cfg *cloudconfig.CloudConfig) *LoadBalancerController {
 	return &LoadBalancerController{
-		partitionID:              partitionID,
-		projectID:                projectID,
-		clusterID:                clusterID,
-		defaultExternalNetworkID: defaultExternalNetworkID,
-		additionalNetworks:       sets.New(additionalNetworks...),
-		configWriteMutex:         &sync.Mutex{},
+		partitionID:              cfg.PartitionID,
+		projectID:                cfg.ProjectID,
+		clusterID:                cfg.ClusterID,
+		defaultExternalNetworkID: cfg.Networks.DefaultExternalNetworkID,
+		additionalNetworks:       sets.New(cfg.Networks.AdditionalNetworks...),
+		configQueue:              newConfigQueue(),
+		configDebounce:           configDebounce,
 		ipAllocateMutex:          &sync.Mutex{},
 		ipUpdateMutex:            &sync.Mutex{},
-		loadBalancerType:         loadBalancerType,
+		loadBalancerType:         cfg.LoadBalancer.Type,
+		autoAssignPoolSize:       cfg.LoadBalancer.AutoAssignPoolSize,
+		aggregateAddressPools:    cfg.LoadBalancer.AggregateAddressPools,
 	}
 }
 
@@ -64,6 +78,8 @@ func New(partitionID, projectID, clusterID, defaultExternalNetworkID string, add
 // Parameter 'clusterName' is the name of the cluster as presented to kube-controller-manager.
 func (l *LoadBalancerController) GetLoadBalancer(ctx context.Context, clusterName string, service *v1.Service) (status *v1.LoadBalancerStatus, exists bool, err error) {
 	klog.Infof("GetLoadBalancer: clusterName %q, namespace %q, serviceName %q", clusterName, service.Namespace, service.Name)
+	_, span := tracing.Start(ctx, "LoadBalancerController.GetLoadBalancer")
+	defer span.End()
 
 	if len(service.Status.LoadBalancer.Ingress) == 0 {
 		return nil, false, nil
@@ -90,6 +106,9 @@ func (l *LoadBalancerController) lbName(service *v1.Service) string {
 // Neither 'service' nor 'nodes' are modified.
 // Parameter 'clusterName' is the name of the cluster as presented to kube-controller-manager.
 func (l *LoadBalancerController) EnsureLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) (*v1.LoadBalancerStatus, error) {
+	ctx, span := tracing.Start(ctx, "LoadBalancerController.EnsureLoadBalancer")
+	defer span.End()
+
 	ns := []v1.Node{}
 	for i := range nodes {
 		ns = append(ns, *nodes[i])
@@ -100,7 +119,7 @@ func (l *LoadBalancerController) EnsureLoadBalancer(ctx context.Context, cluster
 
 	fixedIP := service.Spec.LoadBalancerIP
 	if fixedIP != "" {
-		l.ipUpdateMutex.Lock()
+		tracing.Lock(ctx, "ipUpdateMutex", l.ipUpdateMutex)
 		defer l.ipUpdateMutex.Unlock()
 
 		ip, err := l.MetalService.FindProjectIP(ctx, l.projectID, fixedIP)
@@ -132,24 +151,27 @@ func (l *LoadBalancerController) EnsureLoadBalancer(ctx context.Context, cluster
 		return &v1.LoadBalancerStatus{Ingress: ingressStatus}, nil
 	}
 
-	l.ipAllocateMutex.Lock()
+	if addresses := l.serviceIPs(service); len(addresses) > 0 {
+		return l.ensureServiceIPs(ctx, service, addresses)
+	}
+
+	if l.usesAutoAssignPool(service) {
+		return l.ensureAutoAssignedIPs(ctx, service)
+	}
+
+	tracing.Lock(ctx, "ipAllocateMutex", l.ipAllocateMutex)
 	defer l.ipAllocateMutex.Unlock()
 
 	// if we already acquired an IP, we write it into the service status
 	// we do not acquire another IP if there is already an IP present in the service status
 	currentIPCount := len(ingressStatus)
-	var err error
-	var ip string
 	if currentIPCount > 0 {
 		return &v1.LoadBalancerStatus{
 			Ingress: ingressStatus,
 		}, nil
 	}
 
-	ip, err = l.acquireIP(ctx, service)
-	if err != nil {
-		return nil, err
-	}
+	ips, err := l.acquireIPs(ctx, service)
 
 	rollback := func(err error) error {
 		if err == nil {
@@ -158,33 +180,46 @@ func (l *LoadBalancerController) EnsureLoadBalancer(ctx context.Context, cluster
 
 		klog.Errorf("error while trying to ensure load balancer, rolling back ip acquisition: %v", err)
 
-		// clearing tags before release
-		// we can do this because here we know that we freshly acquired a new IP that's not used for anything else
-		_, err2 := l.MetalService.UpdateIP(ctx, &models.V1IPUpdateRequest{
-			Ipaddress: &ip,
-			Tags:      []string{},
-		})
-		if err2 != nil {
-			klog.Errorf("error during ip rollback occurred: %v", err2)
-			return err
-		}
-
-		err2 = l.MetalService.FreeIP(ctx, ip)
-		if err2 != nil {
-			klog.Errorf("error during ip rollback occurred: %v", err2)
-			return err
+		for _, ip := range ips {
+			// clearing tags before release
+			// we can do this because here we know that we freshly acquired a new IP that's not used for anything else
+			_, err2 := l.MetalService.UpdateIP(ctx, &models.V1IPUpdateRequest{
+				Ipaddress: ip.Ipaddress,
+				Tags:      []string{},
+			})
+			if err2 != nil {
+				metrics.ObserveIPOperation(metrics.IPOperationRollback, pointer.SafeDeref(ip.Networkid), err2)
+				klog.Errorf("error during ip rollback occurred: %v", err2)
+				continue
+			}
+
+			err2 = l.MetalService.FreeIP(ctx, *ip.Ipaddress)
+			metrics.ObserveIPOperation(metrics.IPOperationRollback, pointer.SafeDeref(ip.Networkid), err2)
+			if err2 != nil {
+				klog.Errorf("error during ip rollback occurred: %v", err2)
+				continue
+			}
 		}
 
 		return err
 	}
 
+	if err != nil {
+		return nil, rollback(err)
+	}
+
+	var addresses []string
+	for _, ip := range ips {
+		addresses = append(addresses, *ip.Ipaddress)
+	}
+
 	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		s, err := l.K8sClientSet.CoreV1().Services(service.Namespace).Get(ctx, service.Name, metav1.GetOptions{})
 		if err != nil {
 			return err
 		}
 
-		s.Spec.LoadBalancerIP = ip
+		l.setServiceIPs(s, addresses)
 		_, err = l.K8sClientSet.CoreV1().Services(s.Namespace).Update(ctx, s, metav1.UpdateOptions{})
 		return err
 	})
@@ -192,13 +227,12 @@ func (l *LoadBalancerController) EnsureLoadBalancer(ctx context.Context, cluster
 		return nil, rollback(err)
 	}
 
-	ingressStatus = append(ingressStatus, v1.LoadBalancerIngress{IP: ip})
-
-	err = l.UpdateLoadBalancerConfig(ctx, ns)
-	if err != nil {
-		return nil, rollback(err)
+	for _, address := range addresses {
+		ingressStatus = append(ingressStatus, v1.LoadBalancerIngress{IP: address})
 	}
 
+	l.EnqueueConfigUpdate()
+
 	return &v1.LoadBalancerStatus{
 		Ingress: ingressStatus,
 	}, nil
@@ -208,11 +242,12 @@ func (l *LoadBalancerController) EnsureLoadBalancer(ctx context.Context, cluster
 // Neither 'service' nor 'nodes' are modified.
 // Parameter 'clusterName' is the name of the cluster as presented to kube-controller-manager.
 func (l *LoadBalancerController) UpdateLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) error {
-	ns := []v1.Node{}
-	for i := range nodes {
-		ns = append(ns, *nodes[i])
-	}
-	return l.UpdateLoadBalancerConfig(ctx, ns)
+	_, span := tracing.Start(ctx, "LoadBalancerController.UpdateLoadBalancer")
+	defer span.End()
+
+	l.EnqueueConfigUpdate()
+
+	return nil
 }
 
 // EnsureLoadBalancerDeleted deletes the cluster load balancer if it
@@ -225,10 +260,12 @@ func (l *LoadBalancerController) UpdateLoadBalancer(ctx context.Context, cluster
 // Parameter 'clusterName' is the name of the cluster as presented to kube-controller-manager
 func (l *LoadBalancerController) EnsureLoadBalancerDeleted(ctx context.Context, clusterName string, service *v1.Service) error {
 	klog.Infof("EnsureLoadBalancerDeleted: clusterName %q, namespace %q, serviceName %q, serviceStatus: %v", clusterName, service.Namespace, service.Name, service.Status)
+	ctx, span := tracing.Start(ctx, "LoadBalancerController.EnsureLoadBalancerDeleted")
+	defer span.End()
 
 	serviceTag := tags.BuildClusterServiceFQNTag(l.clusterID, service.GetNamespace(), service.GetName())
 
-	l.ipUpdateMutex.Lock()
+	tracing.Lock(ctx, "ipUpdateMutex", l.ipUpdateMutex)
 	defer l.ipUpdateMutex.Unlock()
 
 	ips, err := l.MetalService.FindProjectIPsWithTag(ctx, l.projectID, serviceTag)
@@ -259,6 +296,7 @@ func (l *LoadBalancerController) removeServiceTagFromIPs(ctx context.Context, se
 					klog.Infof("freeing unused ephemeral ip: %s, tags: %s", *ip.Ipaddress, ip.Tags)
 
 					err := l.MetalService.FreeIP(ctx, *ip.Ipaddress)
+					metrics.ObserveIPOperation(metrics.IPOperationFree, pointer.SafeDeref(ip.Networkid), err)
 					if err != nil {
 						return fmt.Errorf("unable to delete ip %s: %w", *ip.Ipaddress, err)
 					}
@@ -295,21 +333,6 @@ func (l *LoadBalancerController) removeServiceTag(ip models.V1IPResponse, servic
 	return newTags, len(newTags) == 0
 }
 
-// UpdateLoadBalancerConfig updates the load balancer config for the given nodes
-func (l *LoadBalancerController) UpdateLoadBalancerConfig(ctx context.Context, nodes []v1.Node) error {
-	l.configWriteMutex.Lock()
-	defer l.configWriteMutex.Unlock()
-
-	err := l.updateLoadBalancerConfig(ctx, nodes)
-	if err != nil {
-		return err
-	}
-
-	klog.Info("load balancer config updated successfully")
-
-	return nil
-}
-
 func (l *LoadBalancerController) useIPInCluster(ctx context.Context, ip models.V1IPResponse, clusterID string, s v1.Service) (*models.V1IPResponse, error) {
 	tm := tag.NewTagMap(ip.Tags)
 
@@ -332,30 +355,232 @@ func (l *LoadBalancerController) useIPInCluster(ctx context.Context, ip models.V
 	return resp, err
 }
 
-func (l *LoadBalancerController) acquireIP(ctx context.Context, service *v1.Service) (string, error) {
+// usesAutoAssignPool returns true if the load balancer implementation picks the ip for this service from the auto-assign pool.
+// this is the case if the auto-assign pool is enabled and the service does not request a specific ip or network.
+func (l *LoadBalancerController) usesAutoAssignPool(service *v1.Service) bool {
+	if l.autoAssignPoolSize <= 0 || service.Spec.LoadBalancerIP != "" {
+		return false
+	}
+
 	annotations := service.GetAnnotations()
+	if _, ok := annotations[constants.MetalNetworksAnnotation]; ok {
+		return false
+	}
+	if _, ok := annotations[constants.MetalLBSpecificAddressPool]; ok {
+		return false
+	}
+
+	return true
+}
+
+// autoAssignRetryDelay is the time after which a service waiting for an ip from the auto-assign pool is reconciled again
+const autoAssignRetryDelay = 5 * time.Second
+
+// ensureAutoAssignedIPs reconciles the ips that were picked from the auto-assign pool by the load balancer implementation
+// back into the metal-api by tagging them with the service tag.
+func (l *LoadBalancerController) ensureAutoAssignedIPs(ctx context.Context, service *v1.Service) (*v1.LoadBalancerStatus, error) {
+	ingressStatus := service.Status.LoadBalancer.Ingress
+
+	if len(ingressStatus) == 0 {
+		// make sure the auto-assign pool is present. the service controller does not react to status changes,
+		// so the service is requeued on purpose to tag the ip once the load balancer implementation assigned it.
+		l.EnqueueConfigUpdate()
+
+		return nil, cloudproviderapi.NewRetryError(fmt.Sprintf("waiting for %s to assign an ip from the auto-assign pool", l.loadBalancerType), autoAssignRetryDelay)
+	}
+
+	tracing.Lock(ctx, "ipUpdateMutex", l.ipUpdateMutex)
+	defer l.ipUpdateMutex.Unlock()
+
+	serviceTag := tags.BuildClusterServiceFQNTag(l.clusterID, service.GetNamespace(), service.GetName())
+
+	for _, ingress := range ingressStatus {
+		if ingress.IP == "" {
+			continue
+		}
+
+		ip, err := l.MetalService.FindProjectIP(ctx, l.projectID, ingress.IP)
+		if err != nil {
+			return nil, err
+		}
+
+		if !tags.IsAutoAssign(ip.Tags, l.clusterID) || slices.Contains(ip.Tags, serviceTag) {
+			continue
+		}
+
+		_, err = l.useIPInCluster(ctx, *ip, l.clusterID, *service)
+		if err != nil {
+			return nil, fmt.Errorf("could not associate auto-assigned ip:%s, err: %w", ingress.IP, err)
+		}
+	}
+
+	return &v1.LoadBalancerStatus{
+		Ingress: ingressStatus,
+	}, nil
+}
+
+// ensureAutoAssignPool allocates ips in the default network until the auto-assign pool has the configured number of free ips,
+// ips of the pool which are already used by a service are not counted.
+func (l *LoadBalancerController) ensureAutoAssignPool(ctx context.Context, ips []*models.V1IPResponse) ([]*models.V1IPResponse, error) {
+	if l.autoAssignPoolSize <= 0 {
+		return ips, nil
+	}
+
+	free := 0
+	for _, ip := range ips {
+		if tags.IsAutoAssign(ip.Tags, l.clusterID) && !tags.IsUsedByService(ip.Tags) && pointer.SafeDeref(ip.Networkid) == l.defaultExternalNetworkID {
+			free++
+		}
+	}
+
+	for ; free < l.autoAssignPoolSize; free++ {
+		ip, err := l.MetalService.AllocateAutoAssignIP(ctx, constants.IPPrefix, l.projectID, l.defaultExternalNetworkID, l.clusterID)
+		metrics.ObserveIPOperation(metrics.IPOperationAllocate, l.defaultExternalNetworkID, err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to acquire ip for the auto-assign pool in network %q: %w", l.defaultExternalNetworkID, err)
+		}
+
+		klog.Infof("acquired ip for the auto-assign pool in network %q: %v", l.defaultExternalNetworkID, *ip.Ipaddress)
+
+		ips = append(ips, ip)
+	}
+
+	return ips, nil
+}
+
+// acquireIPs acquires one ip in every network requested by the service.
+// the ips acquired so far are also returned in case of an error, such that they can be rolled back.
+func (l *LoadBalancerController) acquireIPs(ctx context.Context, service *v1.Service) ([]*models.V1IPResponse, error) {
+	networks, err := l.networksOfService(service)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []*models.V1IPResponse
+	for _, nw := range networks {
+		ip, err := l.acquireIPFromSpecificNetwork(ctx, service, nw)
+		if err != nil {
+			return ips, err
+		}
+		ips = append(ips, ip)
+	}
+
+	return ips, nil
+}
+
+// networksOfService returns the networks in which ips should be acquired for the given service.
+func (l *LoadBalancerController) networksOfService(service *v1.Service) ([]string, error) {
+	annotations := service.GetAnnotations()
+
+	if networksString, ok := annotations[constants.MetalNetworksAnnotation]; ok {
+		var networks []string
+		for nw := range strings.SplitSeq(networksString, ",") {
+			nw = strings.TrimSpace(nw)
+			if nw == "" || slices.Contains(networks, nw) {
+				continue
+			}
+			if len(l.additionalNetworks) > 0 && !l.additionalNetworks.Has(nw) {
+				return nil, fmt.Errorf("network %q is not part of the cluster networks, ips in this network would not be announced", nw)
+			}
+			networks = append(networks, nw)
+		}
+		if len(networks) == 0 {
+			return nil, fmt.Errorf("annotation %q does not contain any network", constants.MetalNetworksAnnotation)
+		}
+		return networks, nil
+	}
+
 	addressPool, ok := annotations[constants.MetalLBSpecificAddressPool]
 	if !ok {
 		if l.defaultExternalNetworkID == "" {
-			return "", fmt.Errorf(`no default network for ip acquisition specified, acquire an ip for your cluster's project and specify it directly in "spec.loadBalancerIP"`)
+			return nil, fmt.Errorf(`no default network for ip acquisition specified, acquire an ip for your cluster's project and specify it directly in "spec.loadBalancerIP"`)
 		}
 
-		return l.acquireIPFromSpecificNetwork(ctx, service, l.defaultExternalNetworkID)
+		return []string{l.defaultExternalNetworkID}, nil
 	}
-	return l.acquireIPFromSpecificNetwork(ctx, service, addressPool)
+	// the address pool is named after the network and the ip type
+	nwID := strings.TrimSuffix(addressPool, "-"+models.V1IPBaseTypeEphemeral)
+	nwID = strings.TrimSuffix(nwID, "-"+models.V1IPBaseTypeStatic)
+
+	return []string{nwID}, nil
 }
 
-func (l *LoadBalancerController) acquireIPFromSpecificNetwork(ctx context.Context, service *v1.Service, addressPoolName string) (string, error) {
-	nwID := strings.TrimSuffix(addressPoolName, "-"+models.V1IPBaseTypeEphemeral)
-	nwID = strings.TrimSuffix(nwID, "-"+models.V1IPBaseTypeEphemeral)
+// serviceIPs returns the ips which were acquired for a service with multiple networks, they are kept in an annotation
+// as the spec of the service only holds a single ip.
+func (l *LoadBalancerController) serviceIPs(s *v1.Service) []string {
+	annotation := constants.MetalLBLoadBalancerIPs
+	if l.loadBalancerType == config.LoadBalancerTypeCilium {
+		annotation = constants.CiliumLoadBalancerIPs
+	}
+
+	var ips []string
+	for ip := range strings.SplitSeq(s.GetAnnotations()[annotation], ",") {
+		if ip = strings.TrimSpace(ip); ip != "" {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// ensureServiceIPs makes sure the given ips of a service with multiple networks are tagged with the service tag,
+// such that no further ips are acquired when the service is synced again.
+func (l *LoadBalancerController) ensureServiceIPs(ctx context.Context, service *v1.Service, addresses []string) (*v1.LoadBalancerStatus, error) {
+	tracing.Lock(ctx, "ipUpdateMutex", l.ipUpdateMutex)
+	defer l.ipUpdateMutex.Unlock()
+
+	serviceTag := tags.BuildClusterServiceFQNTag(l.clusterID, service.GetNamespace(), service.GetName())
+
+	var ingressStatus []v1.LoadBalancerIngress
+	for _, address := range addresses {
+		ip, err := l.MetalService.FindProjectIP(ctx, l.projectID, address)
+		if err != nil {
+			return nil, err
+		}
+
+		if !slices.Contains(ip.Tags, serviceTag) {
+			_, err = l.useIPInCluster(ctx, *ip, l.clusterID, *service)
+			if err != nil {
+				return nil, fmt.Errorf("could not associate ip:%s, err: %w", address, err)
+			}
+		}
+
+		ingressStatus = append(ingressStatus, v1.LoadBalancerIngress{IP: address})
+	}
+
+	return &v1.LoadBalancerStatus{
+		Ingress: ingressStatus,
+	}, nil
+}
+
+// setServiceIPs writes the acquired ips into the given service.
+// a single ip is written to "spec.loadBalancerIP", multiple ips are passed through the load balancer specific annotation.
+func (l *LoadBalancerController) setServiceIPs(s *v1.Service, ips []string) {
+	if len(ips) == 1 {
+		s.Spec.LoadBalancerIP = ips[0]
+		return
+	}
+
+	annotation := constants.MetalLBLoadBalancerIPs
+	if l.loadBalancerType == config.LoadBalancerTypeCilium {
+		annotation = constants.CiliumLoadBalancerIPs
+	}
+
+	if s.Annotations == nil {
+		s.Annotations = map[string]string{}
+	}
+	s.Annotations[annotation] = strings.Join(ips, ",")
+}
+
+func (l *LoadBalancerController) acquireIPFromSpecificNetwork(ctx context.Context, service *v1.Service, nwID string) (*models.V1IPResponse, error) {
 	ip, err := l.MetalService.AllocateIP(ctx, *service, constants.IPPrefix, l.projectID, nwID, l.clusterID)
+	metrics.ObserveIPOperation(metrics.IPOperationAllocate, nwID, err)
 	if err != nil {
-		return "", fmt.Errorf("failed to acquire IPs for project %q in network %q: %w", l.projectID, nwID, err)
+		return nil, fmt.Errorf("failed to acquire IPs for project %q in network %q: %w", l.projectID, nwID, err)
 	}
 
 	klog.Infof("acquired ip in network %q: %v", nwID, *ip.Ipaddress)
 
-	return *ip.Ipaddress, nil
+	return ip, nil
 }
 
 func (l *LoadBalancerController) updateLoadBalancerConfig(ctx context.Context, nodes []v1.Node) error {
@@ -364,12 +589,21 @@ func (l *LoadBalancerController) updateLoadBalancerConfig(ctx context.Context, n
 		return fmt.Errorf("could not find ips of this project's cluster: %w", err)
 	}
 
-	cfg, err := config.New(l.loadBalancerType, ips, l.additionalNetworks, nodes, l.K8sClient, l.K8sClientSet)
+	ips, err = l.ensureAutoAssignPool(ctx, ips)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.New(l.loadBalancerType, ips, l.additionalNetworks, l.clusterID, l.aggregateAddressPools, nodes, l.K8sClient, l.K8sClientSet)
 	if err != nil {
 		return err
 	}
 
-	err = cfg.WriteCRs(ctx)
+	start := time.Now()
+	spanCtx, span := tracing.Start(ctx, "WriteCRs", attribute.String("backend", string(l.loadBalancerType)))
+	err = cfg.WriteCRs(spanCtx)
+	tracing.End(span, err)
+	metrics.ObserveWriteCRs(string(l.loadBalancerType), start, err)
 	if err != nil {
 		return err
 	}
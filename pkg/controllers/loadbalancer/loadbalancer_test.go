@@ -4,7 +4,12 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/metal-stack/metal-ccm/pkg/controllers/loadbalancer/config"
+	"github.com/metal-stack/metal-ccm/pkg/resources/constants"
 	"github.com/metal-stack/metal-go/api/models"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 func TestLoadBalancerController_removeServiceTag(t *testing.T) {
@@ -93,3 +98,161 @@ func TestLoadBalancerController_removeServiceTag(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadBalancerController_networksOfService(t *testing.T) {
+	tests := []struct {
+		name               string
+		defaultNetwork     string
+		additionalNetworks []string
+		annotations        map[string]string
+		want               []string
+		wantErr            bool
+	}{
+		{
+			name:               "default network",
+			defaultNetwork:     "internet",
+			additionalNetworks: []string{"internet"},
+			want:               []string{"internet"},
+		},
+		{
+			name:    "no default network",
+			wantErr: true,
+		},
+		{
+			name:           "address pool annotation",
+			defaultNetwork: "internet",
+			annotations:    map[string]string{constants.MetalLBSpecificAddressPool: "partner-network-ephemeral"},
+			want:           []string{"partner-network"},
+		},
+		{
+			name:           "static address pool annotation",
+			defaultNetwork: "internet",
+			annotations:    map[string]string{constants.MetalLBSpecificAddressPool: "partner-network-static"},
+			want:           []string{"partner-network"},
+		},
+		{
+			name:               "multiple networks",
+			defaultNetwork:     "internet",
+			additionalNetworks: []string{"internet", "partner-network"},
+			annotations:        map[string]string{constants.MetalNetworksAnnotation: "internet, partner-network,internet"},
+			want:               []string{"internet", "partner-network"},
+		},
+		{
+			name:               "network not part of the cluster networks",
+			defaultNetwork:     "internet",
+			additionalNetworks: []string{"internet"},
+			annotations:        map[string]string{constants.MetalNetworksAnnotation: "internet,partner-network"},
+			wantErr:            true,
+		},
+		{
+			name:           "empty networks annotation",
+			defaultNetwork: "internet",
+			annotations:    map[string]string{constants.MetalNetworksAnnotation: " , "},
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &LoadBalancerController{
+				defaultExternalNetworkID: tt.defaultNetwork,
+				additionalNetworks:       sets.New(tt.additionalNetworks...),
+			}
+
+			got, err := l.networksOfService(&v1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerController_setServiceIPs(t *testing.T) {
+	tests := []struct {
+		name             string
+		loadBalancerType config.LoadBalancerType
+		ips              []string
+		wantIP           string
+		wantAnnotations  map[string]string
+	}{
+		{
+			name:             "single ip",
+			loadBalancerType: config.LoadBalancerTypeMetalLB,
+			ips:              []string{"84.1.1.1"},
+			wantIP:           "84.1.1.1",
+		},
+		{
+			name:             "multiple ips with metallb",
+			loadBalancerType: config.LoadBalancerTypeMetalLB,
+			ips:              []string{"84.1.1.1", "10.1.1.1"},
+			wantAnnotations:  map[string]string{constants.MetalLBLoadBalancerIPs: "84.1.1.1,10.1.1.1"},
+		},
+		{
+			name:             "multiple ips with cilium",
+			loadBalancerType: config.LoadBalancerTypeCilium,
+			ips:              []string{"84.1.1.1", "10.1.1.1"},
+			wantAnnotations:  map[string]string{constants.CiliumLoadBalancerIPs: "84.1.1.1,10.1.1.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &LoadBalancerController{loadBalancerType: tt.loadBalancerType}
+			s := &v1.Service{}
+
+			l.setServiceIPs(s, tt.ips)
+
+			if s.Spec.LoadBalancerIP != tt.wantIP {
+				t.Errorf("got = %v, want %v", s.Spec.LoadBalancerIP, tt.wantIP)
+			}
+			if !reflect.DeepEqual(s.Annotations, tt.wantAnnotations) {
+				t.Errorf("got = %v, want %v", s.Annotations, tt.wantAnnotations)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerController_usesAutoAssignPool(t *testing.T) {
+	tests := []struct {
+		name               string
+		autoAssignPoolSize int
+		service            *v1.Service
+		want               bool
+	}{
+		{
+			name:               "auto-assign pool disabled",
+			autoAssignPoolSize: 0,
+			service:            &v1.Service{},
+			want:               false,
+		},
+		{
+			name:               "service without specific ip",
+			autoAssignPoolSize: 5,
+			service:            &v1.Service{},
+			want:               true,
+		},
+		{
+			name:               "service with load balancer ip",
+			autoAssignPoolSize: 5,
+			service:            &v1.Service{Spec: v1.ServiceSpec{LoadBalancerIP: "84.1.1.1"}},
+			want:               false,
+		},
+		{
+			name:               "service with specific networks",
+			autoAssignPoolSize: 5,
+			service:            &v1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{constants.MetalNetworksAnnotation: "internet"}}},
+			want:               false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &LoadBalancerController{autoAssignPoolSize: tt.autoAssignPoolSize}
+
+			if got := l.usesAutoAssignPool(tt.service); got != tt.want {
+				t.Errorf("got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package loadbalancer
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+
+	"github.com/metal-stack/metal-ccm/pkg/controllers/loadbalancer/config"
+	"github.com/metal-stack/metal-ccm/pkg/metrics"
+	"github.com/metal-stack/metal-ccm/pkg/resources/kubernetes"
+	"github.com/metal-stack/metal-ccm/pkg/tracing"
+	"github.com/metal-stack/metal-lib/pkg/tag"
+)
+
+const (
+	// configQueueKey reconciles the whole load balancer config
+	configQueueKey = "config"
+	// peersQueueKey is the prefix of the keys which only reconcile the bgp peer of the node named by the rest of the key,
+	// which does not require the ips from the metal-api
+	peersQueueKey = "peers/"
+	// configDebounce is the time triggers are collected before the config is reconciled
+	configDebounce = 1 * time.Second
+)
+
+func newConfigQueue() workqueue.TypedRateLimitingInterface[string] {
+	return workqueue.NewTypedRateLimitingQueueWithConfig(
+		workqueue.NewTypedItemExponentialFailureRateLimiter[string](time.Second, 5*time.Minute),
+		workqueue.TypedRateLimitingQueueConfig[string]{Name: "loadbalancer-config"},
+	)
+}
+
+// EnqueueConfigUpdate requests a reconciliation of the load balancer config.
+// Requests which arrive while a reconciliation is pending are coalesced into a single one.
+func (l *LoadBalancerController) EnqueueConfigUpdate() {
+	l.configQueue.AddAfter(configQueueKey, l.configDebounce)
+}
+
+// EnqueuePeersUpdate requests a reconciliation of the bgp peer of the given node after it changed.
+func (l *LoadBalancerController) EnqueuePeersUpdate(nodeName string) {
+	l.configQueue.AddAfter(peersQueueKey+nodeName, l.configDebounce)
+}
+
+// RunConfigWorker reconciles the load balancer config whenever an update was enqueued until the context is cancelled.
+// Failed reconciliations are retried with an exponential backoff.
+func (l *LoadBalancerController) RunConfigWorker(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		l.configQueue.ShutDown()
+	}()
+
+	for l.processNextConfigUpdate(ctx) {
+	}
+}
+
+func (l *LoadBalancerController) processNextConfigUpdate(ctx context.Context) bool {
+	key, shutdown := l.configQueue.Get()
+	if shutdown {
+		return false
+	}
+	defer l.configQueue.Done(key)
+
+	var err error
+	nodeName, isPeersKey := strings.CutPrefix(key, peersQueueKey)
+	switch {
+	case key == configQueueKey:
+		start := time.Now()
+		err = l.reconcileConfig(ctx)
+		if ctx.Err() != nil {
+			return true
+		}
+		metrics.ObserveLoadBalancerConfigReconcile(start, err)
+		if err != nil {
+			klog.Errorf("updating load balancer config failed %d times, last success: %s, retrying: %v", l.configQueue.NumRequeues(key)+1, l.sinceLastConfigSuccess(), err)
+		} else {
+			l.lastConfigSuccess = time.Now()
+		}
+	case isPeersKey:
+		err = l.reconcilePeer(ctx, nodeName)
+		if ctx.Err() != nil {
+			return true
+		}
+		if err != nil {
+			klog.Errorf("updating bgp peer of node %q failed %d times, retrying: %v", nodeName, l.configQueue.NumRequeues(key)+1, err)
+		}
+	default:
+		klog.Errorf("ignoring unknown key %q in the load balancer config queue", key)
+	}
+
+	if err != nil {
+		l.configQueue.AddRateLimited(key)
+		return true
+	}
+
+	l.configQueue.Forget(key)
+	return true
+}
+
+func (l *LoadBalancerController) sinceLastConfigSuccess() string {
+	if l.lastConfigSuccess.IsZero() {
+		return "never"
+	}
+	return time.Since(l.lastConfigSuccess).Round(time.Second).String() + " ago"
+}
+
+// reconcileConfig writes the load balancer config for all nodes of the cluster.
+func (l *LoadBalancerController) reconcileConfig(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "LoadBalancerController.reconcileConfig")
+	defer span.End()
+
+	nodes, err := kubernetes.GetNodes(ctx, l.K8sClientSet)
+	if err != nil {
+		return err
+	}
+
+	err = l.updateLoadBalancerConfig(ctx, nodes)
+	if err != nil {
+		return err
+	}
+
+	klog.Info("load balancer config updated successfully")
+
+	return nil
+}
+
+// reconcilePeer writes the bgp peer of the given node, the peer is deleted if the node is gone. The peers of other nodes are left untouched.
+func (l *LoadBalancerController) reconcilePeer(ctx context.Context, nodeName string) error {
+	ctx, span := tracing.Start(ctx, "LoadBalancerController.reconcilePeer")
+	defer span.End()
+
+	var nodes []v1.Node
+	node, err := l.K8sClientSet.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+	case err != nil:
+		return err
+	case node.Labels[tag.MachineNetworkPrimaryASN] == "":
+		// retrying does not help, the node is enqueued again when the label is added
+		klog.Warningf("node %q has no %s label, it gets no bgp peer", nodeName, tag.MachineNetworkPrimaryASN)
+	default:
+		nodes = append(nodes, *node)
+	}
+
+	cfg, err := config.NewPeers(l.loadBalancerType, nodes, l.K8sClient, l.K8sClientSet)
+	if err != nil {
+		return err
+	}
+
+	err = cfg.WriteNodePeer(ctx, nodeName)
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("bgp peer of node %q updated successfully", nodeName)
+
+	return nil
+}
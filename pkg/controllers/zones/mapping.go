@@ -0,0 +1,65 @@
+package zones
+
+import (
+	"strings"
+
+	"github.com/metal-stack/metal-go/api/models"
+	cloudprovider "k8s.io/cloud-provider"
+
+	"github.com/metal-stack/metal-ccm/pkg/cloudconfig"
+	"github.com/metal-stack/metal-ccm/pkg/resources/constants"
+	"github.com/metal-stack/metal-ccm/pkg/resources/metal"
+)
+
+// Mapping derives the zone and region of a machine as configured in the cloud config.
+type Mapping struct {
+	cfg cloudconfig.Zones
+}
+
+// NewMapping returns a new zone mapping for the given config.
+func NewMapping(cfg cloudconfig.Zones) *Mapping {
+	return &Mapping{cfg: cfg}
+}
+
+// Zone returns the zone and region of the given machine.
+func (m *Mapping) Zone(machine *models.V1MachineResponse) cloudprovider.Zone {
+	if machine == nil || machine.Partition == nil || machine.Partition.ID == nil {
+		return noZone
+	}
+
+	partitionID := *machine.Partition.ID
+	partition := m.cfg.Partitions[partitionID]
+
+	zone := cloudprovider.Zone{
+		FailureDomain: partitionID,
+		Region:        getRegionFromPartitionID(partitionID),
+	}
+	if partition.Zone != "" {
+		zone.FailureDomain = partition.Zone
+	}
+	if partition.Region != "" {
+		zone.Region = partition.Region
+	}
+
+	var topologyLabel string
+	switch m.cfg.Source {
+	case cloudconfig.ZoneSourceRack:
+		topologyLabel = constants.TopologyRackLabel
+	case cloudconfig.ZoneSourceSwitchPair:
+		topologyLabel = constants.TopologySwitchPairLabel
+	}
+	if topologyLabel != "" {
+		if value, ok := metal.TopologyLabels(machine)[topologyLabel]; ok {
+			zone.FailureDomain = value
+		}
+	}
+
+	return zone
+}
+
+// getRegionFromPartitionID extracts the region from a given partitionID
+func getRegionFromPartitionID(partitionID string) string {
+	// if partitionID contains a hyphen, return part before first hyphen as region, otherwise return partitionID
+	region, _, _ := strings.Cut(partitionID, "-")
+	return region
+}
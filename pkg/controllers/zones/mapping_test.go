@@ -0,0 +1,70 @@
+package zones
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/metal-stack/metal-go/api/models"
+	cloudprovider "k8s.io/cloud-provider"
+
+	"github.com/metal-stack/metal-ccm/pkg/cloudconfig"
+)
+
+func TestMapping_Zone(t *testing.T) {
+	machine := &models.V1MachineResponse{
+		Partition: &models.V1PartitionResponse{ID: new("fra-equ01")},
+		Rackid:    "rack-1",
+	}
+
+	tests := []struct {
+		name    string
+		cfg     cloudconfig.Zones
+		machine *models.V1MachineResponse
+		want    cloudprovider.Zone
+	}{
+		{
+			name:    "partition with region from hyphen",
+			cfg:     cloudconfig.Zones{Source: cloudconfig.ZoneSourcePartition},
+			machine: machine,
+			want:    cloudprovider.Zone{FailureDomain: "fra-equ01", Region: "fra"},
+		},
+		{
+			name: "explicit partition table",
+			cfg: cloudconfig.Zones{
+				Source:     cloudconfig.ZoneSourcePartition,
+				Partitions: map[string]cloudconfig.PartitionZone{"fra-equ01": {Region: "eu-central", Zone: "eu-central-1a"}},
+			},
+			machine: machine,
+			want:    cloudprovider.Zone{FailureDomain: "eu-central-1a", Region: "eu-central"},
+		},
+		{
+			name: "rack",
+			cfg: cloudconfig.Zones{
+				Source:     cloudconfig.ZoneSourceRack,
+				Partitions: map[string]cloudconfig.PartitionZone{"fra-equ01": {Region: "eu-central"}},
+			},
+			machine: machine,
+			want:    cloudprovider.Zone{FailureDomain: "rack-1", Region: "eu-central"},
+		},
+		{
+			name:    "unknown switch pair falls back to the partition",
+			cfg:     cloudconfig.Zones{Source: cloudconfig.ZoneSourceSwitchPair},
+			machine: machine,
+			want:    cloudprovider.Zone{FailureDomain: "fra-equ01", Region: "fra"},
+		},
+		{
+			name:    "machine without partition",
+			cfg:     cloudconfig.Zones{Source: cloudconfig.ZoneSourcePartition},
+			machine: &models.V1MachineResponse{},
+			want:    cloudprovider.Zone{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewMapping(tt.cfg).Zone(tt.machine)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("diff = %v", diff)
+			}
+		})
+	}
+}
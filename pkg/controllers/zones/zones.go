@@ -2,15 +2,16 @@ package zones
 
 import (
 	"context"
-	"strings"
 
 	"github.com/metal-stack/metal-ccm/pkg/resources/metal"
+	"github.com/metal-stack/metal-ccm/pkg/tracing"
 
 	"k8s.io/apimachinery/pkg/types"
 	cloudprovider "k8s.io/cloud-provider"
 )
 
 type ZonesController struct {
+	mapping      *Mapping
 	MetalService *metal.MetalService
 }
 
@@ -19,8 +20,10 @@ var (
 )
 
 // New returns a new zones controller that satisfies the kubernetes cloud provider zones interface
-func New() *ZonesController {
-	return &ZonesController{}
+func New(mapping *Mapping) *ZonesController {
+	return &ZonesController{
+		mapping: mapping,
+	}
 }
 
 // GetZone returns the Zone containing the current failure zone and locality region that the program is running in.
@@ -35,37 +38,28 @@ func (z ZonesController) GetZone(_ context.Context) (cloudprovider.Zone, error)
 // This method is particularly used in the context of external cloud providers where node initialization must be done
 // outside the kubelets.
 func (z ZonesController) GetZoneByProviderID(ctx context.Context, providerID string) (cloudprovider.Zone, error) {
+	ctx, span := tracing.Start(ctx, "ZonesController.GetZoneByProviderID")
+	defer span.End()
+
 	machine, err := z.MetalService.GetMachineFromProviderID(ctx, providerID)
 	if err != nil {
 		return noZone, err
 	}
 
-	// TODO: check if failureDomain == Partition
-	return cloudprovider.Zone{
-		FailureDomain: *machine.Partition.ID,
-		Region:        getRegionFromPartitionID(machine.Partition.ID),
-	}, nil
+	return z.mapping.Zone(machine), nil
 }
 
 // GetZoneByNodeName returns the Zone containing the current zone and locality region of the node specified by node name.
 // This method is particularly used in the context of external cloud providers where node initialization must be done
 // outside the kubelets.
 func (z ZonesController) GetZoneByNodeName(ctx context.Context, nodeName types.NodeName) (cloudprovider.Zone, error) {
+	ctx, span := tracing.Start(ctx, "ZonesController.GetZoneByNodeName")
+	defer span.End()
+
 	machine, err := z.MetalService.GetMachineFromNodeName(ctx, nodeName)
 	if err != nil {
 		return noZone, err
 	}
 
-	// TODO: check if failureDomain == Partition
-	return cloudprovider.Zone{
-		FailureDomain: *machine.Partition.ID,
-		Region:        getRegionFromPartitionID(machine.Partition.ID),
-	}, nil
-}
-
-// getRegionFromPartitionID extracts the region from a given partitionID
-func getRegionFromPartitionID(partitionID *string) string {
-	// if partitionID contains a hyphen, return part before first hyphen as region, otherwise return partitionID
-	split := strings.Split(*partitionID, "-")
-	return split[0]
+	return z.mapping.Zone(machine), nil
 }
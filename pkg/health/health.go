@@ -0,0 +1,100 @@
+package health
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/metal-stack/metal-ccm/pkg/metrics"
+)
+
+// ErrMetalAPIUnavailable is returned by mutating operations while the metal-api is unavailable.
+var ErrMetalAPIUnavailable = errors.New("metal-api is unavailable, mutating operations are paused")
+
+// MetalAPI tracks the reachability of the metal-api based on the results of the periodic health checks.
+// The metal-api is considered unavailable after the configured number of consecutive failed checks.
+type MetalAPI struct {
+	mu               sync.RWMutex
+	failureThreshold int
+	failures         int
+	lastErr          error
+	unavailableSince time.Time
+}
+
+// NewMetalAPI returns a new metal-api health status, the metal-api is considered available until the first failed check.
+func NewMetalAPI(failureThreshold int) *MetalAPI {
+	metrics.ObserveMetalAPIHealth(true, 0)
+	return &MetalAPI{
+		failureThreshold: max(failureThreshold, 1),
+	}
+}
+
+// Report records the result of a health check and returns the number of consecutive failures.
+func (m *MetalAPI) Report(err error) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err == nil {
+		if !m.available() {
+			klog.Infof("metal-api is available again after %s, resuming mutating operations", time.Since(m.unavailableSince).Round(time.Second))
+		}
+		m.failures = 0
+		m.lastErr = nil
+		m.unavailableSince = time.Time{}
+		metrics.ObserveMetalAPIHealth(true, 0)
+		return 0
+	}
+
+	wasAvailable := m.available()
+	m.failures++
+	m.lastErr = err
+	if wasAvailable && !m.available() {
+		m.unavailableSince = time.Now()
+		klog.Errorf("metal-api is unavailable after %d failed health checks, serving cached data and pausing mutating operations: %v", m.failures, err)
+	}
+	metrics.ObserveMetalAPIHealth(m.available(), m.failures)
+
+	return m.failures
+}
+
+// Available returns true if the metal-api is considered reachable.
+func (m *MetalAPI) Available() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.available()
+}
+
+func (m *MetalAPI) available() bool {
+	return m.failures < m.failureThreshold
+}
+
+// CheckMutation returns an error wrapping ErrMetalAPIUnavailable if mutating operations are currently paused.
+func (m *MetalAPI) CheckMutation(operation string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.available() {
+		return nil
+	}
+	return fmt.Errorf("unable to %s: %w since %s: %w", operation, ErrMetalAPIUnavailable, m.unavailableSince.Format(time.RFC3339), m.lastErr)
+}
+
+// Name returns the name of the health check.
+func (m *MetalAPI) Name() string {
+	return "metal-api"
+}
+
+// Check implements the healthz checker interface, it fails while the metal-api is unavailable.
+func (m *MetalAPI) Check(_ *http.Request) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.available() {
+		return nil
+	}
+	return fmt.Errorf("metal-api is unavailable since %s after %d failed health checks: %w", m.unavailableSince.Format(time.RFC3339), m.failures, m.lastErr)
+}
@@ -0,0 +1,46 @@
+package health
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMetalAPI(t *testing.T) {
+	m := NewMetalAPI(2)
+	apiErr := errors.New("connection refused")
+
+	if !m.Available() {
+		t.Fatal("expected metal-api to be available initially")
+	}
+
+	if failures := m.Report(apiErr); failures != 1 {
+		t.Errorf("failures = %d, want 1", failures)
+	}
+	if !m.Available() {
+		t.Error("expected metal-api to be available below the failure threshold")
+	}
+	if err := m.CheckMutation("allocate ip"); err != nil {
+		t.Errorf("expected mutations to be allowed, got %v", err)
+	}
+
+	m.Report(apiErr)
+	if m.Available() {
+		t.Error("expected metal-api to be unavailable after reaching the failure threshold")
+	}
+	if err := m.CheckMutation("allocate ip"); !errors.Is(err, ErrMetalAPIUnavailable) || !errors.Is(err, apiErr) {
+		t.Errorf("expected mutations to be paused, got %v", err)
+	}
+	if err := m.Check(nil); err == nil {
+		t.Error("expected health check to fail")
+	}
+
+	if failures := m.Report(nil); failures != 0 {
+		t.Errorf("failures = %d, want 0", failures)
+	}
+	if !m.Available() {
+		t.Error("expected metal-api to be available after a successful check")
+	}
+	if err := m.Check(nil); err != nil {
+		t.Errorf("expected health check to succeed, got %v", err)
+	}
+}
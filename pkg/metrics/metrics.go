@@ -0,0 +1,302 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const (
+	namespace = "metal_ccm"
+
+	ResultSuccess = "success"
+	ResultError   = "error"
+
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+
+	IPOperationAllocate = "allocate"
+	IPOperationFree     = "free"
+	IPOperationRollback = "rollback"
+
+	TaskAnnotationSync   = "annotation_sync"
+	TaskLoadBalancerSync = "loadbalancer_sync"
+	TaskMetalAPIHealth   = "metal_api_health"
+	TaskSSHKeySync       = "ssh_key_sync"
+	TaskTagSync          = "tag_sync"
+)
+
+var (
+	ipOperations = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      "loadbalancer",
+			Name:           "ip_operations_total",
+			Help:           "Number of ip allocations, frees and rollbacks by network and result.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"operation", "network", "result"},
+	)
+
+	metalAPIRequestDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      namespace,
+			Subsystem:      "metal_api",
+			Name:           "request_duration_seconds",
+			Help:           "Latency of metal-api requests by operation.",
+			Buckets:        metrics.DefBuckets,
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"operation"},
+	)
+
+	metalAPIRequestErrors = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      "metal_api",
+			Name:           "request_errors_total",
+			Help:           "Number of failed metal-api requests by operation.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"operation"},
+	)
+
+	machineCacheRequests = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      "machine_cache",
+			Name:           "requests_total",
+			Help:           "Number of machine cache lookups by cache and result (hit or miss).",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"cache", "result"},
+	)
+
+	writeCRsDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      namespace,
+			Subsystem:      "loadbalancer",
+			Name:           "write_crs_duration_seconds",
+			Help:           "Duration of writing the load balancer custom resources by backend and result.",
+			Buckets:        metrics.DefBuckets,
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"backend", "result"},
+	)
+
+	objectsChanged = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      "loadbalancer",
+			Name:           "objects_changed_total",
+			Help:           "Number of load balancer objects created, updated or deleted by backend and kind.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"backend", "kind", "operation"},
+	)
+
+	configReconcileDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      namespace,
+			Subsystem:      "loadbalancer",
+			Name:           "config_reconcile_duration_seconds",
+			Help:           "Duration of load balancer config reconciliations by result.",
+			Buckets:        metrics.DefBuckets,
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"result"},
+	)
+
+	configLastSuccess = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Namespace:      namespace,
+			Subsystem:      "loadbalancer",
+			Name:           "config_last_success_timestamp_seconds",
+			Help:           "Unix time of the last successful load balancer config reconciliation, the time since is time() minus this value.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	housekeepingDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      namespace,
+			Subsystem:      "housekeeping",
+			Name:           "task_duration_seconds",
+			Help:           "Duration of housekeeping tasks.",
+			Buckets:        metrics.DefBuckets,
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"task"},
+	)
+
+	housekeepingFailures = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      "housekeeping",
+			Name:           "task_failures_total",
+			Help:           "Number of failed housekeeping task runs.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"task"},
+	)
+
+	metalAPIAvailable = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Namespace:      namespace,
+			Subsystem:      "metal_api",
+			Name:           "available",
+			Help:           "Whether the metal-api is considered available (1) or unavailable (0) based on the health checks.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	metalAPIHealthCheckFailures = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Namespace:      namespace,
+			Subsystem:      "metal_api",
+			Name:           "consecutive_health_check_failures",
+			Help:           "Number of consecutive failed metal-api health checks.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	metalAPIRequestsRejected = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      "metal_api",
+			Name:           "requests_rejected_total",
+			Help:           "Number of metal-api requests rejected by the open circuit breaker by operation.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"operation"},
+	)
+
+	metalAPIRateLimiterWait = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      namespace,
+			Subsystem:      "metal_api",
+			Name:           "rate_limiter_wait_seconds",
+			Help:           "Time metal-api requests waited for the client-side rate limiter by operation.",
+			Buckets:        []float64{0.001, 0.01, 0.05, 0.1, 0.5, 1, 5, 10},
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"operation"},
+	)
+
+	metalAPICircuitBreakerState = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Namespace:      namespace,
+			Subsystem:      "metal_api",
+			Name:           "circuit_breaker_state",
+			Help:           "State of the metal-api circuit breaker, 0 is closed, 1 is half-open and 2 is open.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	registerOnce sync.Once
+)
+
+// Register registers the metal-ccm specific metrics with the legacy registry.
+func Register() {
+	registerOnce.Do(func() {
+		legacyregistry.MustRegister(
+			ipOperations,
+			metalAPIRequestDuration,
+			metalAPIRequestErrors,
+			machineCacheRequests,
+			writeCRsDuration,
+			objectsChanged,
+			configReconcileDuration,
+			configLastSuccess,
+			housekeepingDuration,
+			housekeepingFailures,
+			metalAPIAvailable,
+			metalAPIHealthCheckFailures,
+			metalAPIRequestsRejected,
+			metalAPIRateLimiterWait,
+			metalAPICircuitBreakerState,
+		)
+	})
+}
+
+// ObserveIPOperation records an ip allocation, free or rollback in the given network.
+func ObserveIPOperation(operation, network string, err error) {
+	ipOperations.WithLabelValues(operation, network, result(err)).Inc()
+}
+
+// ObserveMetalAPIRequest records the latency and the outcome of a metal-api request started at the given time.
+func ObserveMetalAPIRequest(operation string, start time.Time, err error) {
+	metalAPIRequestDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metalAPIRequestErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// ObserveMachineCacheRequest records a lookup in the given machine cache.
+func ObserveMachineCacheRequest(cache string, miss bool) {
+	r := CacheHit
+	if miss {
+		r = CacheMiss
+	}
+	machineCacheRequests.WithLabelValues(cache, r).Inc()
+}
+
+// ObserveWriteCRs records the duration of writing the load balancer custom resources started at the given time.
+func ObserveWriteCRs(backend string, start time.Time, err error) {
+	writeCRsDuration.WithLabelValues(backend, result(err)).Observe(time.Since(start).Seconds())
+}
+
+// ObserveObjectChanged records a created, updated or deleted load balancer object.
+func ObserveObjectChanged(backend, kind, operation string) {
+	objectsChanged.WithLabelValues(backend, kind, operation).Inc()
+}
+
+// ObserveLoadBalancerConfigReconcile records the duration and the outcome of a load balancer config reconciliation started at the given time.
+func ObserveLoadBalancerConfigReconcile(start time.Time, err error) {
+	configReconcileDuration.WithLabelValues(result(err)).Observe(time.Since(start).Seconds())
+	if err == nil {
+		configLastSuccess.SetToCurrentTime()
+	}
+}
+
+// ObserveHousekeepingTask records the duration and the outcome of a housekeeping task started at the given time.
+func ObserveHousekeepingTask(task string, start time.Time, err error) {
+	housekeepingDuration.WithLabelValues(task).Observe(time.Since(start).Seconds())
+	if err != nil {
+		housekeepingFailures.WithLabelValues(task).Inc()
+	}
+}
+
+// ObserveMetalAPIHealth records the availability of the metal-api and the number of consecutive failed health checks.
+func ObserveMetalAPIHealth(available bool, failures int) {
+	if available {
+		metalAPIAvailable.Set(1)
+	} else {
+		metalAPIAvailable.Set(0)
+	}
+	metalAPIHealthCheckFailures.Set(float64(failures))
+}
+
+// ObserveMetalAPIRejected records a metal-api request which was rejected by the open circuit breaker.
+func ObserveMetalAPIRejected(operation string) {
+	metalAPIRequestsRejected.WithLabelValues(operation).Inc()
+}
+
+// ObserveMetalAPIRateLimiterWait records the time a metal-api request waited for the rate limiter since the given time.
+func ObserveMetalAPIRateLimiterWait(operation string, start time.Time) {
+	metalAPIRateLimiterWait.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// ObserveCircuitBreakerState records the current state of the metal-api circuit breaker.
+func ObserveCircuitBreakerState(state int) {
+	metalAPICircuitBreakerState.Set(float64(state))
+}
+
+func result(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return ResultSuccess
+}
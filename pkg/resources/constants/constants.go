@@ -5,12 +5,16 @@ const (
 	//nolint
 	MetalAuthTokenEnvVar              = "METAL_AUTH_TOKEN"
 	MetalAuthHMACEnvVar               = "METAL_AUTH_HMAC"
+	MetalAuthTokenFileEnvVar          = "METAL_AUTH_TOKEN_FILE"
+	MetalAuthHMACFileEnvVar           = "METAL_AUTH_HMAC_FILE"
 	MetalAuthHMACAuthTypeEnvVar       = "METAL_AUTH_HMAC_AUTH_TYPE"
 	MetalProjectIDEnvVar              = "METAL_PROJECT_ID"
 	MetalPartitionIDEnvVar            = "METAL_PARTITION_ID"
 	MetalClusterIDEnvVar              = "METAL_CLUSTER_ID"
 	MetalDefaultExternalNetworkEnvVar = "METAL_DEFAULT_EXTERNAL_NETWORK_ID"
 	MetalAdditionalNetworks           = "METAL_ADDITIONAL_NETWORKS"
+	MetalAutoAssignPoolSize           = "METAL_AUTO_ASSIGN_POOL_SIZE"
+	MetalAggregateAddressPools        = "METAL_AGGREGATE_ADDRESS_POOLS"
 
 	// MetalSSHPublicKey latest ssh public key
 	MetalSSHPublicKey = "METAL_SSH_PUBLICKEY"
@@ -20,6 +24,59 @@ const (
 	// FIXME this annotation is deprecated metallb.io should be used instead
 	MetalLBSpecificAddressPool = "metallb.universe.tf/address-pool"
 
+	// MetalNetworksAnnotation can be set on a service to acquire one ip in each of the given comma-separated networks
+	MetalNetworksAnnotation = "metal-stack.io/networks"
+	// ManagedLabelsAnnotation lists the comma-separated keys of the node labels which were set from machine tags,
+	// only these labels are removed when their tag disappears
+	ManagedLabelsAnnotation = "metal-stack.io/managed-labels"
+	// ManagedTaintsAnnotation lists the comma-separated key:effect pairs of the node taints which were set from machine tags,
+	// only these taints are removed when their tag disappears
+	ManagedTaintsAnnotation = "metal-stack.io/managed-taints"
+	// MachineIDAnnotation is the node annotation of the id of the machine
+	MachineIDAnnotation = "metal-stack.io/machine-id"
+	// MachineImageAnnotation is the node annotation of the image the machine was installed with
+	MachineImageAnnotation = "metal-stack.io/image"
+	// MachineBIOSAnnotation is the node annotation of the bios vendor, version and date of the machine
+	MachineBIOSAnnotation = "metal-stack.io/bios"
+	// MachineAllocatedAtAnnotation is the node annotation of the time the machine was allocated
+	MachineAllocatedAtAnnotation = "metal-stack.io/allocated-at"
+	// MachineLivelinessAnnotation is the node annotation of the liveliness of the machine, Alive, Dead or Unknown
+	MachineLivelinessAnnotation = "metal-stack.io/liveliness"
+	// MachineBMCVersionAnnotation is the node annotation of the firmware version of the bmc of the machine
+	MachineBMCVersionAnnotation = "metal-stack.io/bmc-version"
+	// MachineSizeLabel is the node label of the size of the machine
+	MachineSizeLabel = "metal-stack.io/size"
+	// MachinePartitionLabel is the node label of the partition of the machine
+	MachinePartitionLabel = "metal-stack.io/partition"
+	// MachineProjectLabel is the node label of the project the machine is allocated in
+	MachineProjectLabel = "metal-stack.io/project"
+	// MachineImageLabel is the node label of the image the machine was installed with
+	MachineImageLabel = "metal-stack.io/image"
+	// HardwareCPUCoresLabel is the node label of the number of cpu cores of the machine
+	HardwareCPUCoresLabel = "hardware.metal-stack.io/cpu-cores"
+	// HardwareMemoryLabel is the node label of the memory of the machine in whole gibibytes, e.g. 256Gi
+	HardwareMemoryLabel = "hardware.metal-stack.io/memory"
+	// HardwareDiskCountLabel is the node label of the number of disks of the machine
+	HardwareDiskCountLabel = "hardware.metal-stack.io/disk-count"
+	// HardwareDiskTypeLabel is the node label of the sorted disk types of the machine joined by "-", e.g. nvme or nvme-sd
+	HardwareDiskTypeLabel = "hardware.metal-stack.io/disk-type"
+	// HardwareStorageLabel is the node label of the total disk capacity of the machine in whole gibibytes
+	HardwareStorageLabel = "hardware.metal-stack.io/storage"
+	// HardwareGPUCountLabel is the node label of the number of gpus of the machine
+	HardwareGPUCountLabel = "hardware.metal-stack.io/gpu-count"
+	// HardwareGPUVendorLabel is the node label of the sanitized gpu vendor of the machine, e.g. nvidia-corporation
+	HardwareGPUVendorLabel = "hardware.metal-stack.io/gpu-vendor"
+	// TopologyRackLabel is the node label of the rack the machine is placed in
+	TopologyRackLabel = "topology.metal-stack.io/rack"
+	// TopologyChassisLabel is the node label of the chassis the machine is placed in
+	TopologyChassisLabel = "topology.metal-stack.io/chassis"
+	// TopologySwitchPairLabel is the node label of the leaf switches the machine is connected to
+	TopologySwitchPairLabel = "topology.metal-stack.io/switch-pair"
+	// MetalLBLoadBalancerIPs is used to pass more than one ip address of a service to metallb
+	MetalLBLoadBalancerIPs = "metallb.io/loadBalancerIPs"
+	// CiliumLoadBalancerIPs is used to pass more than one ip address of a service to cilium
+	CiliumLoadBalancerIPs = "lbipam.cilium.io/ips"
+
 	IPPrefix = "metallb-"
 
 	Loadbalancer = "LOADBALANCER"
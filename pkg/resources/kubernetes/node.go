@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"maps"
+	"slices"
 	"strings"
 
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/util/wait"
 	clientset "k8s.io/client-go/kubernetes"
 
@@ -13,6 +15,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"k8s.io/client-go/util/retry"
+
+	"github.com/metal-stack/metal-ccm/pkg/resources/constants"
 )
 
 // GetNodes returns all nodes of this cluster.
@@ -24,8 +28,10 @@ func GetNodes(ctx context.Context, client clientset.Interface) ([]v1.Node, error
 	return nodes.Items, nil
 }
 
-// UpdateNodeLabelsWithBackoff updates labels on a given node with a given backoff retry.
-func UpdateNodeLabelsWithBackoff(ctx context.Context, client clientset.Interface, nodeName string, labels map[string]string, backoff wait.Backoff) error {
+// SyncManagedNodeLabelsWithBackoff sets the given labels on a node and removes the labels which were set by an earlier sync but are not given anymore.
+// The keys of the synced labels are recorded in the managed labels annotation of the node, labels set by others are never touched,
+// even if a given label has the same key. The node is only updated if a label or the annotation changed.
+func SyncManagedNodeLabelsWithBackoff(ctx context.Context, client clientset.Interface, nodeName string, labels map[string]string, backoff wait.Backoff) error {
 	return retry.RetryOnConflict(backoff, func() error {
 
 		node, err := client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
@@ -33,7 +39,39 @@ func UpdateNodeLabelsWithBackoff(ctx context.Context, client clientset.Interface
 			return err
 		}
 
-		maps.Copy(node.Labels, labels)
+		managed := strings.Split(node.Annotations[constants.ManagedLabelsAnnotation], ",")
+
+		result := map[string]string{}
+		for key, value := range node.Labels {
+			if slices.Contains(managed, key) {
+				continue
+			}
+			result[key] = value
+		}
+		var keys []string
+		for key, value := range labels {
+			// never take over a label which was set by someone else
+			if _, ok := result[key]; ok {
+				continue
+			}
+			result[key] = value
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		if maps.Equal(node.Labels, result) && node.Annotations[constants.ManagedLabelsAnnotation] == strings.Join(keys, ",") {
+			return nil
+		}
+
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Labels = result
+		if len(keys) > 0 {
+			node.Annotations[constants.ManagedLabelsAnnotation] = strings.Join(keys, ",")
+		} else {
+			delete(node.Annotations, constants.ManagedLabelsAnnotation)
+		}
 
 		_, err = client.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
 		return err
@@ -56,6 +94,47 @@ func UpdateNodeAnnotationsWithBackoff(ctx context.Context, client clientset.Inte
 	})
 }
 
+// SyncNodeAnnotationsWithBackoff sets the given annotations on a node and removes the given keys which have no annotation anymore.
+// The node is only updated if an annotation changed.
+func SyncNodeAnnotationsWithBackoff(ctx context.Context, client clientset.Interface, nodeName string, keys []string, annotations map[string]string, backoff wait.Backoff) error {
+	return retry.RetryOnConflict(backoff, func() error {
+
+		node, err := client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if !NodeAnnotationsChanged(*node, keys, annotations) {
+			return nil
+		}
+
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		for _, key := range keys {
+			if _, ok := annotations[key]; !ok {
+				delete(node.Annotations, key)
+			}
+		}
+		maps.Copy(node.Annotations, annotations)
+
+		_, err = client.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// NodeAnnotationsChanged returns true if the annotations of the node with the given keys differ from the given annotations.
+func NodeAnnotationsChanged(node v1.Node, keys []string, annotations map[string]string) bool {
+	for _, key := range keys {
+		current, exists := node.Annotations[key]
+		desired, ok := annotations[key]
+		if exists != ok || current != desired {
+			return true
+		}
+	}
+	return false
+}
+
 // NodeNamesOfNodes returns the node names of the nodes
 func NodeNamesOfNodes(nodes []v1.Node) string {
 	var nn []string
@@ -73,3 +152,75 @@ func NodeAddress(node v1.Node) (string, error) {
 	}
 	return "", fmt.Errorf("unable to determine node address")
 }
+
+// NodeReady returns false if the node reports that it is not ready, nodes without a ready condition yet are considered ready.
+func NodeReady(node v1.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == v1.NodeReady {
+			return c.Status == v1.ConditionTrue
+		}
+	}
+	return true
+}
+
+// NodeExcludedFromLoadBalancers returns true if the node is labeled to be excluded from external load balancers.
+func NodeExcludedFromLoadBalancers(node v1.Node) bool {
+	_, ok := node.Labels[v1.LabelNodeExcludeBalancers]
+	return ok
+}
+
+// SyncManagedNodeTaintsWithBackoff sets the given taints on a node and removes the taints which were set by an earlier sync but are not given anymore.
+// The taints are identified by key and effect, they are recorded in the managed taints annotation of the node, taints set by others are never touched, even if a given taint has the same key and effect.
+func SyncManagedNodeTaintsWithBackoff(ctx context.Context, client clientset.Interface, nodeName string, taints []v1.Taint, backoff wait.Backoff) error {
+	return retry.RetryOnConflict(backoff, func() error {
+
+		node, err := client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+
+		managed := strings.Split(node.Annotations[constants.ManagedTaintsAnnotation], ",")
+
+		var (
+			result []v1.Taint
+			keys   []string
+		)
+		for _, t := range node.Spec.Taints {
+			if slices.Contains(managed, taintKey(t)) {
+				continue
+			}
+			result = append(result, t)
+		}
+		unmanaged := len(result)
+		for _, t := range taints {
+			// never take over a taint which was set by someone else
+			if slices.ContainsFunc(result[:unmanaged], func(existing v1.Taint) bool { return existing.MatchTaint(&t) }) {
+				continue
+			}
+			result = append(result, t)
+			keys = append(keys, taintKey(t))
+		}
+
+		if equality.Semantic.DeepEqual(node.Spec.Taints, result) && node.Annotations[constants.ManagedTaintsAnnotation] == strings.Join(keys, ",") {
+			return nil
+		}
+
+		node.Spec.Taints = result
+		if len(keys) > 0 {
+			node.Annotations[constants.ManagedTaintsAnnotation] = strings.Join(keys, ",")
+		} else {
+			delete(node.Annotations, constants.ManagedTaintsAnnotation)
+		}
+
+		_, err = client.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+func taintKey(t v1.Taint) string {
+	return t.Key + ":" + string(t.Effect)
+}
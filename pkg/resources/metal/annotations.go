@@ -0,0 +1,60 @@
+package metal
+
+import (
+	"strings"
+	"time"
+
+	"github.com/metal-stack/metal-go/api/models"
+
+	"github.com/metal-stack/metal-ccm/pkg/resources/constants"
+)
+
+// MachineAnnotationKeys are the keys of the node annotations which are derived from the machine.
+var MachineAnnotationKeys = []string{
+	constants.MachineIDAnnotation,
+	constants.MachineImageAnnotation,
+	constants.MachineBIOSAnnotation,
+	constants.MachineAllocatedAtAnnotation,
+	constants.MachineLivelinessAnnotation,
+	constants.MachineBMCVersionAnnotation,
+}
+
+// MachineAnnotations returns the node annotations with the details of the given machine which help while debugging:
+// its id, image, bios, allocation time, liveliness and the firmware version of its bmc. Details which are unknown are omitted.
+func MachineAnnotations(machine *models.V1MachineResponse, ipmi *models.V1MachineIPMI) map[string]string {
+	annotations := map[string]string{}
+	if machine == nil {
+		return annotations
+	}
+
+	set := func(key, value string) {
+		if value != "" {
+			annotations[key] = value
+		}
+	}
+
+	set(constants.MachineIDAnnotation, deref(machine.ID))
+	set(constants.MachineLivelinessAnnotation, deref(machine.Liveliness))
+	if machine.Bios != nil {
+		var bios []string
+		for _, v := range []*string{machine.Bios.Vendor, machine.Bios.Version, machine.Bios.Date} {
+			if v := strings.TrimSpace(deref(v)); v != "" {
+				bios = append(bios, v)
+			}
+		}
+		set(constants.MachineBIOSAnnotation, strings.Join(bios, " "))
+	}
+	if a := machine.Allocation; a != nil {
+		if a.Image != nil {
+			set(constants.MachineImageAnnotation, deref(a.Image.ID))
+		}
+		if a.Created != nil && !time.Time(*a.Created).IsZero() {
+			set(constants.MachineAllocatedAtAnnotation, time.Time(*a.Created).UTC().Format(time.RFC3339))
+		}
+	}
+	if ipmi != nil {
+		set(constants.MachineBMCVersionAnnotation, strings.TrimSpace(deref(ipmi.Bmcversion)))
+	}
+
+	return annotations
+}
@@ -0,0 +1,57 @@
+package metal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/metal-stack/metal-go/api/models"
+
+	"github.com/metal-stack/metal-ccm/pkg/cloudconfig"
+)
+
+// Backend contains the metal-api operations required by the metal-ccm.
+//
+// The models of the metal-go client are used as the common representation of machines and ips for all backends.
+// Errors returned for failed requests must implement Code() int with the HTTP status code of the failure,
+// such that the circuit breaker and the logging can tell client errors from server errors.
+type Backend interface {
+	// FindMachine returns the machine with the given id.
+	FindMachine(ctx context.Context, id string) (*models.V1MachineResponse, error)
+	// FindMachines returns all machines matching the given request.
+	FindMachines(ctx context.Context, req *models.V1MachineFindRequest) ([]*models.V1MachineResponse, error)
+	// FindMachineIPMI returns the details of the bmc of the given machine, e.g. its power state and firmware version.
+	// The credentials of the bmc are never returned, nil is returned if the machine has no bmc.
+	FindMachineIPMI(ctx context.Context, id string) (*models.V1MachineIPMI, error)
+	// UpdateMachineTags replaces the tags of the given machine.
+	UpdateMachineTags(ctx context.Context, id string, tags []string) error
+	// UpdateMachineSSHKeys replaces the ssh public keys of the allocation of the given machine.
+	UpdateMachineSSHKeys(ctx context.Context, id string, sshPublicKeys []string) error
+
+	// FindIPs returns all ips matching the given request.
+	FindIPs(ctx context.Context, req *models.V1IPFindRequest) ([]*models.V1IPResponse, error)
+	// AllocateIP allocates a new ip.
+	AllocateIP(ctx context.Context, req *models.V1IPAllocateRequest) (*models.V1IPResponse, error)
+	// UpdateIP updates the given ip, tags are replaced if set.
+	UpdateIP(ctx context.Context, req *models.V1IPUpdateRequest) (*models.V1IPResponse, error)
+	// FreeIP releases the given ip address.
+	FreeIP(ctx context.Context, ip string) error
+
+	// Health returns an error if the metal-api is not reachable or not healthy.
+	Health(ctx context.Context) error
+	// WatchCredentials reloads the credentials when the configured credential files change until stop is closed.
+	WatchCredentials(stop <-chan struct{}) error
+}
+
+// NewBackend returns the backend for the metal-api version of the given config.
+func NewBackend(cfg cloudconfig.MetalAPI) (Backend, error) {
+	switch cfg.Version {
+	case cloudconfig.MetalAPIVersionV1:
+		client, err := NewClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewMetalGoBackend(client), nil
+	default:
+		return nil, fmt.Errorf("unknown metal-api version: %q", cfg.Version)
+	}
+}
@@ -0,0 +1,120 @@
+package metal
+
+import (
+	"context"
+	"fmt"
+
+	metalgo "github.com/metal-stack/metal-go"
+	"github.com/metal-stack/metal-go/api/client/health"
+	"github.com/metal-stack/metal-go/api/client/ip"
+	"github.com/metal-stack/metal-go/api/client/machine"
+	"github.com/metal-stack/metal-go/api/models"
+	"github.com/metal-stack/metal-lib/pkg/healthstatus"
+)
+
+// metalGoBackend implements the backend with the swagger based metal-go client of the v1 api.
+type metalGoBackend struct {
+	client metalgo.Client
+}
+
+// NewMetalGoBackend returns a backend for the v1 api using the given metal-go client.
+func NewMetalGoBackend(client metalgo.Client) Backend {
+	return &metalGoBackend{client: client}
+}
+
+func (b *metalGoBackend) FindMachine(ctx context.Context, id string) (*models.V1MachineResponse, error) {
+	resp, err := b.client.Machine().FindMachine(machine.NewFindMachineParams().WithContext(ctx).WithID(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Payload, nil
+}
+
+func (b *metalGoBackend) FindMachines(ctx context.Context, req *models.V1MachineFindRequest) ([]*models.V1MachineResponse, error) {
+	resp, err := b.client.Machine().FindMachines(machine.NewFindMachinesParams().WithContext(ctx).WithBody(req), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Payload, nil
+}
+
+func (b *metalGoBackend) FindMachineIPMI(ctx context.Context, id string) (*models.V1MachineIPMI, error) {
+	resp, err := b.client.Machine().FindIPMIMachine(machine.NewFindIPMIMachineParams().WithContext(ctx).WithID(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	ipmi := resp.Payload.Ipmi
+	if ipmi == nil {
+		return nil, nil
+	}
+	ipmi.User = nil
+	ipmi.Password = nil
+	return ipmi, nil
+}
+
+func (b *metalGoBackend) UpdateMachineTags(ctx context.Context, id string, tags []string) error {
+	_, err := b.client.Machine().UpdateMachine(machine.NewUpdateMachineParams().WithContext(ctx).WithBody(&models.V1MachineUpdateRequest{
+		ID:   &id,
+		Tags: tags,
+	}), nil)
+	return err
+}
+
+func (b *metalGoBackend) UpdateMachineSSHKeys(ctx context.Context, id string, sshPublicKeys []string) error {
+	_, err := b.client.Machine().UpdateMachine(machine.NewUpdateMachineParams().WithContext(ctx).WithBody(&models.V1MachineUpdateRequest{
+		ID:         &id,
+		SSHPubKeys: sshPublicKeys,
+	}), nil)
+	return err
+}
+
+func (b *metalGoBackend) FindIPs(ctx context.Context, req *models.V1IPFindRequest) ([]*models.V1IPResponse, error) {
+	resp, err := b.client.IP().FindIPs(ip.NewFindIPsParams().WithBody(req).WithContext(ctx), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Payload, nil
+}
+
+func (b *metalGoBackend) AllocateIP(ctx context.Context, req *models.V1IPAllocateRequest) (*models.V1IPResponse, error) {
+	resp, err := b.client.IP().AllocateIP(ip.NewAllocateIPParams().WithBody(req).WithContext(ctx), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Payload, nil
+}
+
+func (b *metalGoBackend) UpdateIP(ctx context.Context, req *models.V1IPUpdateRequest) (*models.V1IPResponse, error) {
+	resp, err := b.client.IP().UpdateIP(ip.NewUpdateIPParams().WithBody(req).WithContext(ctx), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Payload, nil
+}
+
+func (b *metalGoBackend) FreeIP(ctx context.Context, address string) error {
+	_, err := b.client.IP().FreeIP(ip.NewFreeIPParams().WithID(address).WithContext(ctx), nil)
+	return err
+}
+
+func (b *metalGoBackend) Health(ctx context.Context) error {
+	resp, err := b.client.Health().Health(health.NewHealthParams().WithContext(ctx), nil)
+	if err != nil {
+		return err
+	}
+
+	if resp.Payload != nil && resp.Payload.Status != nil && *resp.Payload.Status != string(healthstatus.HealthStatusHealthy) {
+		return fmt.Errorf("metal-api reported status %q", *resp.Payload.Status)
+	}
+
+	return nil
+}
+
+func (b *metalGoBackend) WatchCredentials(stop <-chan struct{}) error {
+	// only the reloading client knows about credential files, static clients are used as is
+	c, ok := b.client.(*Client)
+	if !ok {
+		return nil
+	}
+	return c.WatchCredentials(stop)
+}
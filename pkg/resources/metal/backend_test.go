@@ -0,0 +1,220 @@
+package metal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/metal-stack/metal-go/api/models"
+	"github.com/metal-stack/metal-lib/pkg/tag"
+
+	"github.com/metal-stack/metal-ccm/pkg/resources/metal/fake"
+)
+
+// backends are all backend implementations, every backend must pass the conformance tests against the fake metal-api.
+var backends = []struct {
+	name string
+	new  func(api *fake.MetalAPI) Backend
+}{
+	{
+		name: "metal-go",
+		new: func(api *fake.MetalAPI) Backend {
+			return NewMetalGoBackend(api.Client())
+		},
+	},
+}
+
+func TestBackendConformance(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			t.Run("machines", func(t *testing.T) {
+				testBackendMachines(t, b.new)
+			})
+			t.Run("ips", func(t *testing.T) {
+				testBackendIPs(t, b.new)
+			})
+			t.Run("health", func(t *testing.T) {
+				testBackendHealth(t, b.new)
+			})
+		})
+	}
+}
+
+func testBackendMachines(t *testing.T, newBackend func(api *fake.MetalAPI) Backend) {
+	ctx := context.Background()
+
+	api := fake.New()
+	api.AddMachine(&models.V1MachineResponse{
+		ID: new("machine-a"),
+		Allocation: &models.V1MachineAllocation{
+			Hostname: new("node-a"),
+			Project:  new("project-a"),
+		},
+		Tags: []string{"rack=rack-1"},
+	})
+	api.AddMachine(&models.V1MachineResponse{
+		ID: new("machine-b"),
+		Allocation: &models.V1MachineAllocation{
+			Hostname: new("node-a"),
+			Project:  new("project-b"),
+		},
+	})
+	b := newBackend(api)
+
+	m, err := b.FindMachine(ctx, "machine-a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *m.ID != "machine-a" {
+		t.Errorf("machine id = %q, want %q", *m.ID, "machine-a")
+	}
+
+	_, err = b.FindMachine(ctx, "unknown")
+	wantCode(t, err, http.StatusNotFound)
+
+	api.SetPowerState("machine-a", "OFF")
+	api.SetBMCVersion("machine-a", "1.2.3")
+	ipmi, err := b.FindMachineIPMI(ctx, "machine-a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(&models.V1MachineIPMI{Powerstate: new("OFF"), Bmcversion: new("1.2.3")}, ipmi); diff != "" {
+		t.Errorf("diff = %v", diff)
+	}
+	ipmi, err = b.FindMachineIPMI(ctx, "machine-b")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ipmi != nil {
+		t.Errorf("expected no ipmi details without bmc, got %v", ipmi)
+	}
+	_, err = b.FindMachineIPMI(ctx, "unknown")
+	wantCode(t, err, http.StatusNotFound)
+
+	machines, err := b.FindMachines(ctx, &models.V1MachineFindRequest{AllocationHostname: "node-a", AllocationProject: "project-b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(machines) != 1 || *machines[0].ID != "machine-b" {
+		t.Errorf("expected to find machine-b, got %v", machines)
+	}
+
+	err = b.UpdateMachineTags(ctx, "machine-a", []string{"rack=rack-1", tag.ClusterID + "=cluster-a"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = b.UpdateMachineSSHKeys(ctx, "machine-a", []string{"ssh-ed25519 AAAA"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m, err = b.FindMachine(ctx, "machine-a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"rack=rack-1", tag.ClusterID + "=cluster-a"}, m.Tags); diff != "" {
+		t.Errorf("diff = %v", diff)
+	}
+	if diff := cmp.Diff([]string{"ssh-ed25519 AAAA"}, m.Allocation.SSHPubKeys); diff != "" {
+		t.Errorf("diff = %v", diff)
+	}
+
+	err = b.UpdateMachineTags(ctx, "unknown", nil)
+	wantCode(t, err, http.StatusNotFound)
+}
+
+func testBackendIPs(t *testing.T, newBackend func(api *fake.MetalAPI) Backend) {
+	ctx := context.Background()
+
+	api := fake.New()
+	api.AddNetwork("internet", "185.1.2.0/24")
+	api.AddIP(&models.V1IPResponse{
+		Ipaddress: new("185.1.2.1"),
+		Networkid: new("internet"),
+		Projectid: new("project-a"),
+		Type:      new(models.V1IPBaseTypeStatic),
+		Tags:      []string{tag.MachineID + "=machine-a"},
+	})
+	b := newBackend(api)
+
+	allocated, err := b.AllocateIP(ctx, &models.V1IPAllocateRequest{
+		Name:      "service-ip",
+		Networkid: new("internet"),
+		Projectid: new("project-a"),
+		Type:      new(models.V1IPBaseTypeEphemeral),
+		Tags:      []string{"service=a"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *allocated.Ipaddress != "185.1.2.2" {
+		t.Errorf("allocated ip = %q, want %q", *allocated.Ipaddress, "185.1.2.2")
+	}
+
+	_, err = b.AllocateIP(ctx, &models.V1IPAllocateRequest{
+		Networkid: new("unknown"),
+		Projectid: new("project-a"),
+	})
+	wantCode(t, err, http.StatusNotFound)
+
+	ips, err := b.FindIPs(ctx, &models.V1IPFindRequest{Projectid: "project-a", Tags: []string{"service=a"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ips) != 1 || *ips[0].Ipaddress != "185.1.2.2" {
+		t.Errorf("expected to find the allocated ip, got %v", ips)
+	}
+
+	updated, err := b.UpdateIP(ctx, &models.V1IPUpdateRequest{
+		Ipaddress: new("185.1.2.2"),
+		Tags:      []string{"service=a", "service=b"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"service=a", "service=b"}, updated.Tags); diff != "" {
+		t.Errorf("diff = %v", diff)
+	}
+	if updated.Name != "service-ip" {
+		t.Errorf("expected name to be kept, got %q", updated.Name)
+	}
+
+	err = b.FreeIP(ctx, "185.1.2.2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = b.FreeIP(ctx, "185.1.2.2")
+	wantCode(t, err, http.StatusNotFound)
+
+	err = b.FreeIP(ctx, "185.1.2.1")
+	wantCode(t, err, http.StatusUnprocessableEntity)
+}
+
+func testBackendHealth(t *testing.T, newBackend func(api *fake.MetalAPI) Backend) {
+	api := fake.New()
+	b := newBackend(api)
+
+	if err := b.Health(context.Background()); err != nil {
+		t.Errorf("expected metal-api to be healthy, got %v", err)
+	}
+
+	api.SetHealthy(false)
+	if err := b.Health(context.Background()); err == nil {
+		t.Error("expected metal-api to be unhealthy")
+	}
+}
+
+func wantCode(t *testing.T, err error, code int) {
+	t.Helper()
+
+	var coder interface{ Code() int }
+	if !errors.As(err, &coder) {
+		t.Errorf("expected error with code %d, got %v", code, err)
+		return
+	}
+	if coder.Code() != code {
+		t.Errorf("error code = %d, want %d", coder.Code(), code)
+	}
+}
@@ -0,0 +1,233 @@
+package metal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/klog/v2"
+
+	"github.com/metal-stack/metal-ccm/pkg/cloudconfig"
+
+	metalgo "github.com/metal-stack/metal-go"
+	"github.com/metal-stack/metal-go/api/client/audit"
+	"github.com/metal-stack/metal-go/api/client/filesystemlayout"
+	"github.com/metal-stack/metal-go/api/client/firewall"
+	"github.com/metal-stack/metal-go/api/client/firmware"
+	"github.com/metal-stack/metal-go/api/client/health"
+	"github.com/metal-stack/metal-go/api/client/image"
+	"github.com/metal-stack/metal-go/api/client/ip"
+	"github.com/metal-stack/metal-go/api/client/machine"
+	"github.com/metal-stack/metal-go/api/client/network"
+	"github.com/metal-stack/metal-go/api/client/partition"
+	"github.com/metal-stack/metal-go/api/client/project"
+	"github.com/metal-stack/metal-go/api/client/size"
+	"github.com/metal-stack/metal-go/api/client/sizeimageconstraint"
+	"github.com/metal-stack/metal-go/api/client/switch_operations"
+	"github.com/metal-stack/metal-go/api/client/tenant"
+	"github.com/metal-stack/metal-go/api/client/user"
+	"github.com/metal-stack/metal-go/api/client/version"
+	"github.com/metal-stack/metal-go/api/client/vpn"
+)
+
+// kubernetesDataDir is the symlink which is swapped by the kubelet when a mounted secret changes.
+const kubernetesDataDir = "..data"
+
+// Client is a metalgo.Client whose underlying driver is replaced atomically
+// when the metal-api credentials are rotated.
+type Client struct {
+	cfg        cloudconfig.MetalAPI
+	httpClient *http.Client
+	current    atomic.Pointer[driver]
+	// reloadMutex serializes reloads, the current driver can be read concurrently at any time
+	reloadMutex sync.Mutex
+}
+
+type driver struct {
+	metalgo.Client
+	token string
+	hmac  string
+}
+
+// NewClient returns a new metal-api client with the credentials of the given config.
+func NewClient(cfg cloudconfig.MetalAPI) (*Client, error) {
+	httpClient, err := newHTTPClient(cfg.Transport)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{cfg: cfg, httpClient: httpClient}
+
+	_, err = c.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// reload reads the credentials and replaces the driver if they have changed.
+func (c *Client) reload() (bool, error) {
+	c.reloadMutex.Lock()
+	defer c.reloadMutex.Unlock()
+
+	token, hmac, err := c.cfg.ReadCredentials()
+	if err != nil {
+		return false, err
+	}
+
+	if current := c.current.Load(); current != nil && current.token == token && current.hmac == hmac {
+		return false, nil
+	}
+
+	d, err := newDriver(c.cfg.URL, token, hmac, c.cfg.HMACAuthType, c.httpClient)
+	if err != nil {
+		return false, fmt.Errorf("unable to create metal-api client: %w", err)
+	}
+
+	c.current.Store(&driver{Client: d, token: token, hmac: hmac})
+
+	return true, nil
+}
+
+// WatchCredentials reloads the credentials whenever one of the credential files changes until stop is closed.
+// It returns immediately if the credentials are not read from files.
+func (c *Client) WatchCredentials(stop <-chan struct{}) error {
+	files := c.cfg.CredentialFiles()
+	if len(files) == 0 {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("unable to create credential file watcher: %w", err)
+	}
+
+	// the directories are watched instead of the files because mounted secrets are
+	// updated by swapping a symlink, which would not be noticed when watching the file itself
+	watched := map[string]string{}
+	for _, f := range files {
+		dir := filepath.Dir(f)
+		if _, ok := watched[dir]; ok {
+			continue
+		}
+		err := watcher.Add(dir)
+		if err != nil {
+			return errors.Join(fmt.Errorf("unable to watch credential directory %q: %w", dir, err), watcher.Close())
+		}
+		watched[dir] = filepath.Base(f)
+	}
+
+	klog.Infof("watching metal-api credential files %v for changes", files)
+
+	go func() {
+		defer watcher.Close()
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !isCredentialEvent(event, files) {
+					continue
+				}
+
+				changed, err := c.reload()
+				if err != nil {
+					klog.Errorf("unable to reload metal-api credentials after %s, continuing with the previous credentials: %v", event, err)
+					continue
+				}
+				if changed {
+					klog.Info("metal-api credentials have changed, using the new credentials from now on")
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				klog.Errorf("error watching metal-api credential files: %v", err)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func isCredentialEvent(event fsnotify.Event, files []string) bool {
+	if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+		return false
+	}
+
+	base := filepath.Base(event.Name)
+	if base == kubernetesDataDir {
+		return true
+	}
+	for _, f := range files {
+		if filepath.Clean(event.Name) == filepath.Clean(f) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Client) Audit() audit.ClientService {
+	return c.current.Load().Audit()
+}
+func (c *Client) Filesystemlayout() filesystemlayout.ClientService {
+	return c.current.Load().Filesystemlayout()
+}
+func (c *Client) Firewall() firewall.ClientService {
+	return c.current.Load().Firewall()
+}
+func (c *Client) Firmware() firmware.ClientService {
+	return c.current.Load().Firmware()
+}
+func (c *Client) Health() health.ClientService {
+	return c.current.Load().Health()
+}
+func (c *Client) Image() image.ClientService {
+	return c.current.Load().Image()
+}
+func (c *Client) IP() ip.ClientService {
+	return c.current.Load().IP()
+}
+func (c *Client) Machine() machine.ClientService {
+	return c.current.Load().Machine()
+}
+func (c *Client) Network() network.ClientService {
+	return c.current.Load().Network()
+}
+func (c *Client) Partition() partition.ClientService {
+	return c.current.Load().Partition()
+}
+func (c *Client) Project() project.ClientService {
+	return c.current.Load().Project()
+}
+func (c *Client) Size() size.ClientService {
+	return c.current.Load().Size()
+}
+func (c *Client) Sizeimageconstraint() sizeimageconstraint.ClientService {
+	return c.current.Load().Sizeimageconstraint()
+}
+func (c *Client) SwitchOperations() switch_operations.ClientService {
+	return c.current.Load().SwitchOperations()
+}
+func (c *Client) Tenant() tenant.ClientService {
+	return c.current.Load().Tenant()
+}
+func (c *Client) User() user.ClientService {
+	return c.current.Load().User()
+}
+func (c *Client) Version() version.ClientService {
+	return c.current.Load().Version()
+}
+func (c *Client) VPN() vpn.ClientService {
+	return c.current.Load().VPN()
+}
@@ -0,0 +1,57 @@
+package metal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/metal-stack/metal-ccm/pkg/cloudconfig"
+)
+
+func TestClient_WatchCredentials(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+
+	err := os.WriteFile(tokenFile, []byte("token-a\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewClient(cloudconfig.MetalAPI{URL: "http://metal-api", TokenFile: tokenFile})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := c.current.Load().token; got != "token-a" {
+		t.Fatalf("token = %q, want %q", got, "token-a")
+	}
+
+	changed, err := c.reload()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if changed {
+		t.Error("expected client not to be replaced if the credentials have not changed")
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	err = c.WatchCredentials(stop)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = os.WriteFile(tokenFile, []byte("token-b\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.current.Load().token != "token-b" {
+		if time.Now().After(deadline) {
+			t.Fatalf("token was not reloaded, token = %q", c.current.Load().token)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,523 @@
+// Package fake provides an in-memory fake of the metal-api endpoints used by the metal-ccm.
+package fake
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-openapi/runtime"
+	"github.com/google/uuid"
+	metalgo "github.com/metal-stack/metal-go"
+	"github.com/metal-stack/metal-go/api/client/health"
+	"github.com/metal-stack/metal-go/api/client/ip"
+	"github.com/metal-stack/metal-go/api/client/machine"
+	"github.com/metal-stack/metal-go/api/models"
+	"github.com/metal-stack/metal-lib/httperrors"
+	"github.com/metal-stack/metal-lib/pkg/healthstatus"
+	"github.com/metal-stack/metal-lib/pkg/tag"
+)
+
+// MetalAPI is an in-memory fake of the ip and machine endpoints of the metal-api.
+// It is safe for concurrent use, all returned objects are copies of the stored state.
+type MetalAPI struct {
+	mu       sync.Mutex
+	ips      map[string]*models.V1IPResponse
+	machines map[string]*models.V1MachineResponse
+	// ipmis are the bmc details of the machines by id, machines without ipmi details have no bmc
+	ipmis    map[string]*models.V1MachineIPMI
+	networks map[string]netip.Prefix
+	healthy  bool
+}
+
+// New returns a new fake metal-api without any networks, ips or machines.
+func New() *MetalAPI {
+	return &MetalAPI{
+		ips:      map[string]*models.V1IPResponse{},
+		machines: map[string]*models.V1MachineResponse{},
+		ipmis:    map[string]*models.V1MachineIPMI{},
+		networks: map[string]netip.Prefix{},
+		healthy:  true,
+	}
+}
+
+// Client returns a metal-go client backed by this fake.
+// Only the ip, machine and health endpoints are implemented, calling any other endpoint panics.
+func (f *MetalAPI) Client() metalgo.Client {
+	return &client{
+		ip:      &ipService{f: f},
+		machine: &machineService{f: f},
+		health:  &healthService{f: f},
+	}
+}
+
+// AddNetwork adds a network from whose prefix ips are allocated.
+func (f *MetalAPI) AddNetwork(id, prefix string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.networks[id] = netip.MustParsePrefix(prefix)
+}
+
+// AddIP adds an already allocated ip.
+func (f *MetalAPI) AddIP(i *models.V1IPResponse) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ips[*i.Ipaddress] = cloneIP(i)
+}
+
+// AddMachine adds a machine.
+func (f *MetalAPI) AddMachine(m *models.V1MachineResponse) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.machines[*m.ID] = cloneMachine(m)
+}
+
+// SetPowerState sets the power state reported by the bmc of the machine with the given id.
+func (f *MetalAPI) SetPowerState(id, state string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ipmi(id).Powerstate = new(state)
+}
+
+// SetBMCVersion sets the firmware version reported by the bmc of the machine with the given id.
+func (f *MetalAPI) SetBMCVersion(id, version string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ipmi(id).Bmcversion = new(version)
+}
+
+func (f *MetalAPI) ipmi(id string) *models.V1MachineIPMI {
+	i, ok := f.ipmis[id]
+	if !ok {
+		i = &models.V1MachineIPMI{Password: new("secret"), User: new("admin")}
+		f.ipmis[id] = i
+	}
+	return i
+}
+
+// SetHealthy sets the health status reported by the health endpoint.
+func (f *MetalAPI) SetHealthy(healthy bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.healthy = healthy
+}
+
+// IP returns the ip with the given address.
+func (f *MetalAPI) IP(address string) (*models.V1IPResponse, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i, ok := f.ips[address]
+	if !ok {
+		return nil, false
+	}
+	return cloneIP(i), true
+}
+
+// IPs returns all ips sorted by address.
+func (f *MetalAPI) IPs() []*models.V1IPResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []*models.V1IPResponse
+	for _, i := range f.ips {
+		result = append(result, cloneIP(i))
+	}
+	slices.SortFunc(result, func(a, b *models.V1IPResponse) int {
+		return netip.MustParseAddr(*a.Ipaddress).Compare(netip.MustParseAddr(*b.Ipaddress))
+	})
+	return result
+}
+
+// Machine returns the machine with the given id.
+func (f *MetalAPI) Machine(id string) (*models.V1MachineResponse, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, ok := f.machines[id]
+	if !ok {
+		return nil, false
+	}
+	return cloneMachine(m), true
+}
+
+func (f *MetalAPI) findIPs(req *models.V1IPFindRequest) []*models.V1IPResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []*models.V1IPResponse
+	for _, i := range f.ips {
+		switch {
+		case req.Ipaddress != "" && req.Ipaddress != *i.Ipaddress,
+			req.Projectid != "" && req.Projectid != *i.Projectid,
+			req.Networkid != "" && req.Networkid != *i.Networkid,
+			req.Name != "" && req.Name != i.Name,
+			req.Type != "" && req.Type != *i.Type,
+			req.Machineid != "" && !slices.Contains(i.Tags, fmt.Sprintf("%s=%s", tag.MachineID, req.Machineid)):
+			continue
+		}
+		if !containsAll(i.Tags, req.Tags) {
+			continue
+		}
+		result = append(result, cloneIP(i))
+	}
+	slices.SortFunc(result, func(a, b *models.V1IPResponse) int {
+		return strings.Compare(*a.Ipaddress, *b.Ipaddress)
+	})
+	return result
+}
+
+func (f *MetalAPI) allocateIP(req *models.V1IPAllocateRequest) (*models.V1IPResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if req.Networkid == nil || req.Projectid == nil {
+		return nil, httpError(ip.NewAllocateIPDefault, http.StatusUnprocessableEntity, "network and project are required")
+	}
+
+	prefix, ok := f.networks[*req.Networkid]
+	if !ok {
+		return nil, httpError(ip.NewAllocateIPDefault, http.StatusNotFound, "network %q not found", *req.Networkid)
+	}
+
+	addr := prefix.Masked().Addr().Next()
+	for ; prefix.Contains(addr); addr = addr.Next() {
+		if _, ok := f.ips[addr.String()]; !ok {
+			break
+		}
+	}
+	if !prefix.Contains(addr) {
+		return nil, httpError(ip.NewAllocateIPDefault, http.StatusConflict, "no more ips available in network %q", *req.Networkid)
+	}
+
+	ipType := models.V1IPBaseTypeEphemeral
+	if req.Type != nil {
+		ipType = *req.Type
+	}
+
+	i := &models.V1IPResponse{
+		Allocationuuid: new(uuid.NewString()),
+		Description:    req.Description,
+		Ipaddress:      new(addr.String()),
+		Name:           req.Name,
+		Networkid:      new(*req.Networkid),
+		Projectid:      new(*req.Projectid),
+		Tags:           slices.Clone(req.Tags),
+		Type:           &ipType,
+	}
+	f.ips[*i.Ipaddress] = i
+
+	return cloneIP(i), nil
+}
+
+func (f *MetalAPI) updateIP(req *models.V1IPUpdateRequest) (*models.V1IPResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if req.Ipaddress == nil {
+		return nil, httpError(ip.NewUpdateIPDefault, http.StatusUnprocessableEntity, "ipaddress is required")
+	}
+
+	i, ok := f.ips[*req.Ipaddress]
+	if !ok {
+		return nil, httpError(ip.NewUpdateIPDefault, http.StatusNotFound, "ip %q not found", *req.Ipaddress)
+	}
+
+	if req.Name != "" {
+		i.Name = req.Name
+	}
+	if req.Description != "" {
+		i.Description = req.Description
+	}
+	if req.Type != nil {
+		i.Type = new(*req.Type)
+	}
+	if req.Tags != nil {
+		i.Tags = slices.Clone(req.Tags)
+	}
+
+	return cloneIP(i), nil
+}
+
+func (f *MetalAPI) freeIP(address string) (*models.V1IPResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i, ok := f.ips[address]
+	if !ok {
+		return nil, httpError(ip.NewFreeIPDefault, http.StatusNotFound, "ip %q not found", address)
+	}
+	if _, ok := tag.NewTagMap(i.Tags).Value(tag.MachineID); ok {
+		return nil, httpError(ip.NewFreeIPDefault, http.StatusUnprocessableEntity, "ip %q is used by a machine", address)
+	}
+
+	delete(f.ips, address)
+
+	return i, nil
+}
+
+func (f *MetalAPI) findMachine(id string) (*models.V1MachineResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	m, ok := f.machines[id]
+	if !ok {
+		return nil, httpError(machine.NewFindMachineDefault, http.StatusNotFound, "machine %q not found", id)
+	}
+	return cloneMachine(m), nil
+}
+
+func (f *MetalAPI) findIPMIMachine(id string) (*models.V1MachineIPMIResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	m, ok := f.machines[id]
+	if !ok {
+		return nil, httpError(machine.NewFindIPMIMachineDefault, http.StatusNotFound, "machine %q not found", id)
+	}
+	resp := &models.V1MachineIPMIResponse{ID: m.ID}
+	if i, ok := f.ipmis[id]; ok {
+		ipmi := *i
+		resp.Ipmi = &ipmi
+	}
+	return resp, nil
+}
+
+func (f *MetalAPI) findMachines(req *models.V1MachineFindRequest) []*models.V1MachineResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []*models.V1MachineResponse
+	for _, m := range f.machines {
+		var hostname, project string
+		if m.Allocation != nil {
+			hostname = deref(m.Allocation.Hostname)
+			project = deref(m.Allocation.Project)
+		}
+
+		switch {
+		case req.ID != "" && req.ID != *m.ID,
+			req.AllocationHostname != "" && req.AllocationHostname != hostname,
+			req.AllocationProject != "" && req.AllocationProject != project:
+			continue
+		}
+		if !containsAll(m.Tags, req.Tags) {
+			continue
+		}
+		result = append(result, cloneMachine(m))
+	}
+	slices.SortFunc(result, func(a, b *models.V1MachineResponse) int {
+		return strings.Compare(*a.ID, *b.ID)
+	})
+	return result
+}
+
+func (f *MetalAPI) updateMachine(req *models.V1MachineUpdateRequest) (*models.V1MachineResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if req.ID == nil {
+		return nil, httpError(machine.NewUpdateMachineDefault, http.StatusUnprocessableEntity, "id is required")
+	}
+
+	m, ok := f.machines[*req.ID]
+	if !ok {
+		return nil, httpError(machine.NewUpdateMachineDefault, http.StatusNotFound, "machine %q not found", *req.ID)
+	}
+
+	if req.Description != nil {
+		m.Description = *req.Description
+	}
+	if req.Tags != nil {
+		m.Tags = slices.Clone(req.Tags)
+	}
+	if req.SSHPubKeys != nil {
+		if m.Allocation == nil {
+			return nil, httpError(machine.NewUpdateMachineDefault, http.StatusUnprocessableEntity, "machine %q is not allocated", *req.ID)
+		}
+		m.Allocation.SSHPubKeys = slices.Clone(req.SSHPubKeys)
+	}
+
+	return cloneMachine(m), nil
+}
+
+func (f *MetalAPI) health() *models.RestHealthResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	status := string(healthstatus.HealthStatusHealthy)
+	if !f.healthy {
+		status = string(healthstatus.HealthStatusUnhealthy)
+	}
+	return &models.RestHealthResponse{
+		Message: new(""),
+		Status:  &status,
+	}
+}
+
+type client struct {
+	// Client is embedded to satisfy the interface, the endpoints not implemented by the fake are nil
+	metalgo.Client
+	ip      *ipService
+	machine *machineService
+	health  *healthService
+}
+
+func (c *client) IP() ip.ClientService {
+	return c.ip
+}
+
+func (c *client) Machine() machine.ClientService {
+	return c.machine
+}
+
+func (c *client) Health() health.ClientService {
+	return c.health
+}
+
+type ipService struct {
+	ip.ClientService
+	f *MetalAPI
+}
+
+func (s *ipService) FindIPs(params *ip.FindIPsParams, _ runtime.ClientAuthInfoWriter, _ ...ip.ClientOption) (*ip.FindIPsOK, error) {
+	return &ip.FindIPsOK{Payload: s.f.findIPs(params.Body)}, nil
+}
+
+func (s *ipService) FindIP(params *ip.FindIPParams, _ runtime.ClientAuthInfoWriter, _ ...ip.ClientOption) (*ip.FindIPOK, error) {
+	i, ok := s.f.IP(params.ID)
+	if !ok {
+		return nil, httpError(ip.NewFindIPDefault, http.StatusNotFound, "ip %q not found", params.ID)
+	}
+	return &ip.FindIPOK{Payload: i}, nil
+}
+
+func (s *ipService) AllocateIP(params *ip.AllocateIPParams, _ runtime.ClientAuthInfoWriter, _ ...ip.ClientOption) (*ip.AllocateIPCreated, error) {
+	i, err := s.f.allocateIP(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &ip.AllocateIPCreated{Payload: i}, nil
+}
+
+func (s *ipService) UpdateIP(params *ip.UpdateIPParams, _ runtime.ClientAuthInfoWriter, _ ...ip.ClientOption) (*ip.UpdateIPOK, error) {
+	i, err := s.f.updateIP(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &ip.UpdateIPOK{Payload: i}, nil
+}
+
+func (s *ipService) FreeIP(params *ip.FreeIPParams, _ runtime.ClientAuthInfoWriter, _ ...ip.ClientOption) (*ip.FreeIPOK, error) {
+	i, err := s.f.freeIP(params.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &ip.FreeIPOK{Payload: i}, nil
+}
+
+type machineService struct {
+	machine.ClientService
+	f *MetalAPI
+}
+
+func (s *machineService) FindMachine(params *machine.FindMachineParams, _ runtime.ClientAuthInfoWriter, _ ...machine.ClientOption) (*machine.FindMachineOK, error) {
+	m, err := s.f.findMachine(params.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &machine.FindMachineOK{Payload: m}, nil
+}
+
+func (s *machineService) FindIPMIMachine(params *machine.FindIPMIMachineParams, _ runtime.ClientAuthInfoWriter, _ ...machine.ClientOption) (*machine.FindIPMIMachineOK, error) {
+	m, err := s.f.findIPMIMachine(params.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &machine.FindIPMIMachineOK{Payload: m}, nil
+}
+
+func (s *machineService) FindMachines(params *machine.FindMachinesParams, _ runtime.ClientAuthInfoWriter, _ ...machine.ClientOption) (*machine.FindMachinesOK, error) {
+	return &machine.FindMachinesOK{Payload: s.f.findMachines(params.Body)}, nil
+}
+
+func (s *machineService) UpdateMachine(params *machine.UpdateMachineParams, _ runtime.ClientAuthInfoWriter, _ ...machine.ClientOption) (*machine.UpdateMachineOK, error) {
+	m, err := s.f.updateMachine(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &machine.UpdateMachineOK{Payload: m}, nil
+}
+
+type healthService struct {
+	health.ClientService
+	f *MetalAPI
+}
+
+func (s *healthService) Health(_ *health.HealthParams, _ runtime.ClientAuthInfoWriter, _ ...health.ClientOption) (*health.HealthOK, error) {
+	return &health.HealthOK{Payload: s.f.health()}, nil
+}
+
+// defaultResponse is implemented by the generated default responses of the metal-go client, which are used for errors.
+type defaultResponse interface {
+	error
+	Code() int
+}
+
+// httpError returns an error as the metal-go client would return it for the given status code.
+func httpError[T defaultResponse](newDefault func(int) T, code int, format string, args ...any) error {
+	resp := newDefault(code)
+	setPayload(resp, &httperrors.HTTPErrorResponse{StatusCode: code, Message: fmt.Sprintf(format, args...)})
+	return resp
+}
+
+func setPayload(resp any, payload *httperrors.HTTPErrorResponse) {
+	switch r := resp.(type) {
+	case *ip.AllocateIPDefault:
+		r.Payload = payload
+	case *ip.FindIPDefault:
+		r.Payload = payload
+	case *ip.UpdateIPDefault:
+		r.Payload = payload
+	case *ip.FreeIPDefault:
+		r.Payload = payload
+	case *machine.FindMachineDefault:
+		r.Payload = payload
+	case *machine.UpdateMachineDefault:
+		r.Payload = payload
+	}
+}
+
+func containsAll(tags, required []string) bool {
+	for _, t := range required {
+		if !slices.Contains(tags, t) {
+			return false
+		}
+	}
+	return true
+}
+
+func cloneIP(i *models.V1IPResponse) *models.V1IPResponse {
+	c := *i
+	c.Tags = slices.Clone(i.Tags)
+	return &c
+}
+
+func cloneMachine(m *models.V1MachineResponse) *models.V1MachineResponse {
+	c := *m
+	c.Tags = slices.Clone(m.Tags)
+	if m.Allocation != nil {
+		a := *m.Allocation
+		a.SSHPubKeys = slices.Clone(m.Allocation.SSHPubKeys)
+		c.Allocation = &a
+	}
+	return &c
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,97 @@
+package metal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sony/gobreaker/v2"
+	"golang.org/x/time/rate"
+	"k8s.io/klog/v2"
+
+	"github.com/metal-stack/metal-ccm/pkg/cloudconfig"
+	"github.com/metal-stack/metal-ccm/pkg/metrics"
+)
+
+// Guard limits the rate of requests to the metal-api and fails fast while the metal-api is failing repeatedly.
+// It is shared by all users of the metal-api.
+type Guard struct {
+	limiter *rate.Limiter
+	breaker *gobreaker.TwoStepCircuitBreaker[any]
+}
+
+// NewGuard returns a new guard for the metal-api with the rate limit and the circuit breaker settings of the given config.
+func NewGuard(cfg cloudconfig.MetalAPI) *Guard {
+	threshold := cfg.CircuitBreaker.FailureThreshold
+
+	metrics.ObserveCircuitBreakerState(int(gobreaker.StateClosed))
+
+	return &Guard{
+		limiter: rate.NewLimiter(rate.Limit(cfg.RateLimit.QPS), cfg.RateLimit.Burst),
+		breaker: gobreaker.NewTwoStepCircuitBreaker[any](gobreaker.Settings{
+			Name:        "metal-api",
+			MaxRequests: 1,
+			Timeout:     cfg.CircuitBreaker.OpenTimeout.Duration,
+			ReadyToTrip: func(counts gobreaker.Counts) bool {
+				return counts.ConsecutiveFailures >= threshold
+			},
+			OnStateChange: func(name string, from, to gobreaker.State) {
+				metrics.ObserveCircuitBreakerState(int(to))
+				switch to {
+				case gobreaker.StateOpen:
+					klog.Errorf("%s circuit breaker is open after %d consecutive failures, failing requests for %s", name, threshold, cfg.CircuitBreaker.OpenTimeout.Duration)
+				case gobreaker.StateHalfOpen:
+					klog.Infof("%s circuit breaker is half-open, probing with a single request", name)
+				case gobreaker.StateClosed:
+					klog.Infof("%s circuit breaker is closed again, resuming requests", name)
+				}
+			},
+			IsSuccessful: isBreakerSuccess,
+			IsExcluded: func(err error) bool {
+				return errors.Is(err, context.Canceled)
+			},
+		}),
+	}
+}
+
+// admit waits for the rate limiter and checks the circuit breaker, the returned function must be called with the outcome of the request.
+// A nil guard admits every request.
+func (g *Guard) admit(ctx context.Context, operation string) (func(error), error) {
+	if g == nil {
+		return func(error) {}, nil
+	}
+
+	done, err := g.breaker.Allow()
+	if err != nil {
+		metrics.ObserveMetalAPIRejected(operation)
+		return nil, fmt.Errorf("metal-api %s rejected: %w", operation, err)
+	}
+
+	start := time.Now()
+	err = g.limiter.Wait(ctx)
+	metrics.ObserveMetalAPIRateLimiterWait(operation, start)
+	if err != nil {
+		// the request was never sent, so it must not count for the circuit breaker
+		done(context.Canceled)
+		return nil, fmt.Errorf("metal-api %s rate limited: %w", operation, err)
+	}
+
+	return done, nil
+}
+
+// isBreakerSuccess returns true for all responses which indicate that the metal-api is working,
+// including client errors like not found or conflicts.
+func isBreakerSuccess(err error) bool {
+	if err == nil {
+		return true
+	}
+
+	var coder interface{ Code() int }
+	if errors.As(err, &coder) {
+		return coder.Code() < http.StatusInternalServerError
+	}
+
+	return false
+}
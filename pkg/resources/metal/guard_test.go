@@ -0,0 +1,77 @@
+package metal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/metal-stack/metal-ccm/pkg/cloudconfig"
+)
+
+type codeError int
+
+func (e codeError) Error() string { return http.StatusText(int(e)) }
+func (e codeError) Code() int     { return int(e) }
+
+func TestGuard_admit(t *testing.T) {
+	g := NewGuard(cloudconfig.MetalAPI{
+		RateLimit: cloudconfig.RateLimit{QPS: 1000, Burst: 10},
+		CircuitBreaker: cloudconfig.CircuitBreaker{
+			FailureThreshold: 2,
+			OpenTimeout:      &metav1.Duration{Duration: time.Hour},
+		},
+	})
+
+	for _, err := range []error{codeError(http.StatusNotFound), codeError(http.StatusServiceUnavailable), errors.New("connection refused")} {
+		done, admitErr := g.admit(context.Background(), "find_machine")
+		if admitErr != nil {
+			t.Fatalf("unexpected error: %v", admitErr)
+		}
+		done(err)
+	}
+
+	_, err := g.admit(context.Background(), "find_machine")
+	if !errors.Is(err, gobreaker.ErrOpenState) {
+		t.Errorf("expected request to be rejected by the open circuit breaker, got %v", err)
+	}
+}
+
+func Test_isBreakerSuccess(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "no error",
+			want: true,
+		},
+		{
+			name: "client error",
+			err:  codeError(http.StatusConflict),
+			want: true,
+		},
+		{
+			name: "server error",
+			err:  codeError(http.StatusInternalServerError),
+			want: false,
+		},
+		{
+			name: "connection error",
+			err:  errors.New("connection refused"),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isBreakerSuccess(tt.err); got != tt.want {
+				t.Errorf("isBreakerSuccess() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package metal
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/metal-stack/metal-go/api/models"
+
+	"github.com/metal-stack/metal-ccm/pkg/resources/constants"
+)
+
+var invalidLabelValueChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// hardwareLabels returns the capacity labels of the given machine. The hardware inventory of the machine is preferred,
+// the constraints of its size are used for values which are not reported and are only taken if they denote an exact value.
+func hardwareLabels(machine *models.V1MachineResponse) map[string]string {
+	labels := map[string]string{}
+
+	constraints := map[string]*models.V1SizeConstraint{}
+	if machine.Size != nil {
+		for _, c := range machine.Size.Constraints {
+			if c != nil && c.Type != nil && c.Min > 0 && c.Min == c.Max {
+				constraints[*c.Type] = c
+			}
+		}
+	}
+
+	hw := machine.Hardware
+	if hw == nil {
+		hw = &models.V1MachineHardware{}
+	}
+
+	switch {
+	case hw.CPUCores != nil && *hw.CPUCores > 0:
+		labels[constants.HardwareCPUCoresLabel] = strconv.Itoa(int(*hw.CPUCores))
+	case constraints[models.V1SizeConstraintTypeCores] != nil:
+		labels[constants.HardwareCPUCoresLabel] = strconv.FormatInt(constraints[models.V1SizeConstraintTypeCores].Min, 10)
+	}
+
+	switch {
+	case hw.Memory != nil && *hw.Memory > 0:
+		labels[constants.HardwareMemoryLabel] = gibibytes(*hw.Memory)
+	case constraints[models.V1SizeConstraintTypeMemory] != nil:
+		labels[constants.HardwareMemoryLabel] = gibibytes(constraints[models.V1SizeConstraintTypeMemory].Min)
+	}
+
+	var (
+		diskTypes []string
+		storage   int64
+	)
+	for _, d := range hw.Disks {
+		if d == nil {
+			continue
+		}
+		diskTypes = append(diskTypes, diskType(deref(d.Name)))
+		if d.Size != nil {
+			storage += *d.Size
+		}
+	}
+	if len(diskTypes) > 0 {
+		slices.Sort(diskTypes)
+		labels[constants.HardwareDiskCountLabel] = strconv.Itoa(len(diskTypes))
+		labels[constants.HardwareDiskTypeLabel] = strings.Join(slices.Compact(diskTypes), "-")
+	}
+	switch {
+	case storage > 0:
+		labels[constants.HardwareStorageLabel] = gibibytes(storage)
+	case constraints[models.V1SizeConstraintTypeStorage] != nil:
+		labels[constants.HardwareStorageLabel] = gibibytes(constraints[models.V1SizeConstraintTypeStorage].Min)
+	}
+
+	var (
+		gpus    int
+		vendors []string
+	)
+	for _, g := range hw.Gpus {
+		if g == nil {
+			continue
+		}
+		gpus++
+		if vendor := sanitizeLabelValue(deref(g.Vendor)); vendor != "" {
+			vendors = append(vendors, vendor)
+		}
+	}
+	slices.Sort(vendors)
+	switch {
+	case gpus > 0:
+		labels[constants.HardwareGPUCountLabel] = strconv.Itoa(gpus)
+		if len(vendors) > 0 {
+			labels[constants.HardwareGPUVendorLabel] = strings.Join(slices.Compact(vendors), "-")
+		}
+	case constraints[models.V1SizeConstraintTypeGpu] != nil:
+		labels[constants.HardwareGPUCountLabel] = strconv.FormatInt(constraints[models.V1SizeConstraintTypeGpu].Min, 10)
+	}
+
+	return labels
+}
+
+// diskType returns the kind of a disk by its device name, e.g. nvme for /dev/nvme0n1.
+func diskType(name string) string {
+	base := path.Base(name)
+	switch {
+	case strings.HasPrefix(base, "nvme"):
+		return "nvme"
+	case strings.HasPrefix(base, "sd"):
+		return "sd"
+	case strings.HasPrefix(base, "vd"):
+		return "virtio"
+	default:
+		return "other"
+	}
+}
+
+// gibibytes returns the given bytes in whole gibibytes, rounded down so that the value is stable across small deviations of the reported sizes.
+func gibibytes(bytes int64) string {
+	return fmt.Sprintf("%dGi", bytes>>30)
+}
+
+// sanitizeLabelValue lowercases the given value and replaces everything but letters and digits with "-", e.g. "NVIDIA Corporation" becomes nvidia-corporation.
+func sanitizeLabelValue(value string) string {
+	value = strings.Trim(invalidLabelValueChars.ReplaceAllString(strings.ToLower(value), "-"), "-")
+	if len(value) > 63 {
+		value = strings.Trim(value[:63], "-")
+	}
+	return value
+}
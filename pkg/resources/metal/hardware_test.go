@@ -0,0 +1,84 @@
+package metal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/metal-stack/metal-go/api/models"
+
+	"github.com/metal-stack/metal-ccm/pkg/resources/constants"
+)
+
+func Test_hardwareLabels(t *testing.T) {
+	constraint := func(typ string, minimum, maximum int64) *models.V1SizeConstraint {
+		return &models.V1SizeConstraint{Type: new(typ), Min: minimum, Max: maximum}
+	}
+
+	tests := []struct {
+		name    string
+		machine *models.V1MachineResponse
+		want    map[string]string
+	}{
+		{
+			name:    "nothing known",
+			machine: &models.V1MachineResponse{},
+			want:    map[string]string{},
+		},
+		{
+			name: "hardware inventory",
+			machine: &models.V1MachineResponse{
+				Hardware: &models.V1MachineHardware{
+					CPUCores: new(int32(64)),
+					Memory:   new(int64(512<<30 + 1234)),
+					Disks: []*models.V1MachineBlockDevice{
+						{Name: new("/dev/nvme0n1"), Size: new(int64(960 << 30))},
+						{Name: new("/dev/nvme1n1"), Size: new(int64(960 << 30))},
+						{Name: new("/dev/sda"), Size: new(int64(4000 << 30))},
+					},
+					Gpus: []*models.V1MetalGPU{
+						{Vendor: new("NVIDIA Corporation"), Model: new("AD102GL [L40S]")},
+						{Vendor: new("NVIDIA Corporation"), Model: new("AD102GL [L40S]")},
+					},
+				},
+			},
+			want: map[string]string{
+				constants.HardwareCPUCoresLabel:  "64",
+				constants.HardwareMemoryLabel:    "512Gi",
+				constants.HardwareDiskCountLabel: "3",
+				constants.HardwareDiskTypeLabel:  "nvme-sd",
+				constants.HardwareStorageLabel:   "5920Gi",
+				constants.HardwareGPUCountLabel:  "2",
+				constants.HardwareGPUVendorLabel: "nvidia-corporation",
+			},
+		},
+		{
+			name: "exact size constraints are used for unreported values",
+			machine: &models.V1MachineResponse{
+				Size: &models.V1SizeResponse{
+					Constraints: []*models.V1SizeConstraint{
+						constraint(models.V1SizeConstraintTypeCores, 32, 32),
+						constraint(models.V1SizeConstraintTypeMemory, 250<<30, 260<<30),
+						constraint(models.V1SizeConstraintTypeStorage, 1000<<30, 1000<<30),
+						constraint(models.V1SizeConstraintTypeGpu, 4, 4),
+					},
+				},
+				Hardware: &models.V1MachineHardware{
+					CPUCores: new(int32(16)),
+				},
+			},
+			want: map[string]string{
+				constants.HardwareCPUCoresLabel: "16",
+				constants.HardwareStorageLabel:  "1000Gi",
+				constants.HardwareGPUCountLabel: "4",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := hardwareLabels(tt.machine)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("diff = %v", diff)
+			}
+		})
+	}
+}
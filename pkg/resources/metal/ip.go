@@ -6,7 +6,6 @@ import (
 
 	"github.com/metal-stack/metal-ccm/pkg/tags"
 
-	metalip "github.com/metal-stack/metal-go/api/client/ip"
 	"github.com/metal-stack/metal-lib/pkg/tag"
 
 	"github.com/metal-stack/metal-go/api/models"
@@ -21,13 +20,18 @@ func (ms *MetalService) FindClusterIPs(ctx context.Context, projectID, clusterID
 		Projectid: projectID,
 	}
 
-	resp, err := ms.client.IP().FindIPs(metalip.NewFindIPsParams().WithBody(req).WithContext(ctx), nil)
+	ctx, done, err := ms.observe(ctx, "find_ips")
+	if err != nil {
+		return nil, err
+	}
+	ips, err := ms.backend.FindIPs(ctx, req)
+	done(err)
 	if err != nil {
 		return nil, err
 	}
 
 	result := []*models.V1IPResponse{}
-	for _, i := range resp.Payload {
+	for _, i := range ips {
 		tm := tag.NewTagMap(i.Tags)
 
 		if _, ok := tm.Value(tag.ClusterEgress); ok {
@@ -55,16 +59,21 @@ func (ms *MetalService) FindProjectIP(ctx context.Context, projectID, ip string)
 		Projectid: projectID,
 	}
 
-	resp, err := ms.client.IP().FindIPs(metalip.NewFindIPsParams().WithBody(req).WithContext(ctx), nil)
+	ctx, done, err := ms.observe(ctx, "find_ips")
+	if err != nil {
+		return nil, err
+	}
+	ips, err := ms.backend.FindIPs(ctx, req)
+	done(err)
 	if err != nil {
 		return nil, err
 	}
 
-	switch len(resp.Payload) {
+	switch len(ips) {
 	case 0:
 		return nil, fmt.Errorf("ip %s for projectID: %s not allocated", ip, projectID)
 	case 1:
-		return resp.Payload[0], nil
+		return ips[0], nil
 	default:
 		return nil, fmt.Errorf("ip %s is ambiguous for projectID: %s", ip, projectID)
 	}
@@ -77,17 +86,31 @@ func (ms *MetalService) FindProjectIPsWithTag(ctx context.Context, projectID, ta
 		Tags:      []string{tag},
 	}
 
-	resp, err := ms.client.IP().FindIPs(metalip.NewFindIPsParams().WithBody(req).WithContext(ctx), nil)
+	ctx, done, err := ms.observe(ctx, "find_ips")
+	if err != nil {
+		return nil, err
+	}
+	ips, err := ms.backend.FindIPs(ctx, req)
+	done(err)
 	if err != nil {
 		return nil, err
 	}
 
-	return resp.Payload, nil
+	return ips, nil
 }
 
 // FreeIP frees the given IP address.
 func (ms *MetalService) FreeIP(ctx context.Context, ip string) error {
-	_, err := ms.client.IP().FreeIP(metalip.NewFreeIPParams().WithID(ip).WithContext(ctx), nil)
+	if err := ms.health.CheckMutation("free ip"); err != nil {
+		return err
+	}
+
+	ctx, done, err := ms.observe(ctx, "free_ip")
+	if err != nil {
+		return err
+	}
+	err = ms.backend.FreeIP(ctx, ip)
+	done(err)
 	if err != nil {
 		return err
 	}
@@ -96,6 +119,19 @@ func (ms *MetalService) FreeIP(ctx context.Context, ip string) error {
 
 // AllocateIP acquires an IP within the given network for a given project.
 func (ms *MetalService) AllocateIP(ctx context.Context, svc v1.Service, namePrefix, project, network, clusterID string) (*models.V1IPResponse, error) {
+	return ms.allocateIP(ctx, namePrefix, project, network, tags.BuildClusterServiceFQNTag(clusterID, svc.GetNamespace(), svc.GetName()))
+}
+
+// AllocateAutoAssignIP acquires an IP within the given network for the auto-assign pool of the given cluster.
+func (ms *MetalService) AllocateAutoAssignIP(ctx context.Context, namePrefix, project, network, clusterID string) (*models.V1IPResponse, error) {
+	return ms.allocateIP(ctx, namePrefix, project, network, tags.BuildClusterAutoAssignTag(clusterID))
+}
+
+func (ms *MetalService) allocateIP(ctx context.Context, namePrefix, project, network string, ipTags ...string) (*models.V1IPResponse, error) {
+	if err := ms.health.CheckMutation("allocate ip"); err != nil {
+		return nil, err
+	}
+
 	name, err := uuid.NewUUID()
 	if err != nil {
 		return nil, err
@@ -106,23 +142,37 @@ func (ms *MetalService) AllocateIP(ctx context.Context, svc v1.Service, namePref
 		Projectid: &project,
 		Networkid: &network,
 		Type:      new(models.V1IPBaseTypeEphemeral),
-		Tags:      []string{tags.BuildClusterServiceFQNTag(clusterID, svc.GetNamespace(), svc.GetName())},
+		Tags:      ipTags,
 	}
 
-	resp, err := ms.client.IP().AllocateIP(metalip.NewAllocateIPParams().WithBody(req).WithContext(ctx), nil)
+	ctx, done, err := ms.observe(ctx, "allocate_ip")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ms.backend.AllocateIP(ctx, req)
+	done(err)
 	if err != nil {
 		return nil, err
 	}
 
-	return resp.Payload, nil
+	return resp, nil
 }
 
 // UpdateIP updates the given IP address.
 func (ms *MetalService) UpdateIP(ctx context.Context, body *models.V1IPUpdateRequest) (*models.V1IPResponse, error) {
-	resp, err := ms.client.IP().UpdateIP(metalip.NewUpdateIPParams().WithBody(body).WithContext(ctx), nil)
+	if err := ms.health.CheckMutation("update ip"); err != nil {
+		return nil, err
+	}
+
+	ctx, done, err := ms.observe(ctx, "update_ip")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ms.backend.UpdateIP(ctx, body)
+	done(err)
 	if err != nil {
 		return nil, err
 	}
 
-	return resp.Payload, nil
+	return resp, nil
 }
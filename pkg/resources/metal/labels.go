@@ -0,0 +1,54 @@
+package metal
+
+import (
+	"maps"
+
+	"github.com/metal-stack/metal-go/api/models"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/metal-stack/metal-ccm/pkg/resources/constants"
+)
+
+// MachineLabels returns the node labels which describe the given machine: its size, partition, project and image,
+// its hardware capacity and its topology labels. Facts which are unknown or do not form a valid label value are omitted.
+func MachineLabels(machine *models.V1MachineResponse) map[string]string {
+	labels := TopologyLabels(machine)
+	if machine == nil {
+		return labels
+	}
+
+	facts := map[string]string{}
+	if machine.Size != nil {
+		facts[constants.MachineSizeLabel] = deref(machine.Size.ID)
+	}
+	if machine.Partition != nil {
+		facts[constants.MachinePartitionLabel] = deref(machine.Partition.ID)
+	}
+	if machine.Allocation != nil {
+		facts[constants.MachineProjectLabel] = deref(machine.Allocation.Project)
+		if machine.Allocation.Image != nil {
+			facts[constants.MachineImageLabel] = deref(machine.Allocation.Image.ID)
+		}
+	}
+	maps.Copy(facts, hardwareLabels(machine))
+	setValidLabels(labels, facts)
+
+	return labels
+}
+
+// setValidLabels copies the given labels whose value is not empty and valid.
+func setValidLabels(labels, candidates map[string]string) {
+	for key, value := range candidates {
+		if value == "" || len(validation.IsValidLabelValue(value)) > 0 {
+			continue
+		}
+		labels[key] = value
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,68 @@
+package metal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/metal-stack/metal-go/api/models"
+
+	"github.com/metal-stack/metal-ccm/pkg/resources/constants"
+)
+
+func TestMachineLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		machine *models.V1MachineResponse
+		want    map[string]string
+	}{
+		{
+			name:    "no machine",
+			machine: nil,
+			want:    map[string]string{},
+		},
+		{
+			name: "all facts",
+			machine: &models.V1MachineResponse{
+				Size:      &models.V1SizeResponse{ID: new("c1-xlarge-x86")},
+				Partition: &models.V1PartitionResponse{ID: new("fra-equ01")},
+				Rackid:    "rack-1",
+				Allocation: &models.V1MachineAllocation{
+					Project: new("project-a"),
+					Image:   &models.V1ImageResponse{ID: new("ubuntu-24.04")},
+				},
+				Hardware: &models.V1MachineHardware{
+					CPUCores: new(int32(32)),
+					Memory:   new(int64(256 << 30)),
+				},
+			},
+			want: map[string]string{
+				constants.MachineSizeLabel:      "c1-xlarge-x86",
+				constants.MachinePartitionLabel: "fra-equ01",
+				constants.MachineProjectLabel:   "project-a",
+				constants.MachineImageLabel:     "ubuntu-24.04",
+				constants.HardwareCPUCoresLabel: "32",
+				constants.HardwareMemoryLabel:   "256Gi",
+				constants.TopologyRackLabel:     "rack-1",
+			},
+		},
+		{
+			name: "unknown facts are omitted",
+			machine: &models.V1MachineResponse{
+				Size:       &models.V1SizeResponse{},
+				Allocation: &models.V1MachineAllocation{Project: new("project-a")},
+				Hardware:   &models.V1MachineHardware{CPUCores: new(int32(0))},
+			},
+			want: map[string]string{
+				constants.MachineProjectLabel: "project-a",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MachineLabels(tt.machine)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("diff = %v", diff)
+			}
+		})
+	}
+}
@@ -4,14 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/metal-stack/metal-ccm/pkg/health"
+	"github.com/metal-stack/metal-ccm/pkg/metrics"
 	"github.com/metal-stack/metal-ccm/pkg/resources/constants"
+	"github.com/metal-stack/metal-ccm/pkg/tracing"
 	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"github.com/go-openapi/runtime"
 
-	metalgo "github.com/metal-stack/metal-go"
-	"github.com/metal-stack/metal-go/api/client/machine"
 	"github.com/metal-stack/metal-go/api/models"
 
 	"github.com/metal-stack/metal-lib/pkg/cache"
@@ -22,50 +28,69 @@ import (
 )
 
 type MetalService struct {
-	client                 metalgo.Client
+	backend                Backend
 	k8sclient              clientset.Interface
 	machineByUUIDCache     *cache.Cache[string, *models.V1MachineResponse]
 	machineByHostnameCache *cache.Cache[string, *models.V1MachineResponse]
+	// staleMachines holds the last successfully fetched machines, they are returned while the metal-api is unavailable.
+	// The machines of deleted nodes are removed with ForgetNode.
+	staleMachines sync.Map
+	health        *health.MetalAPI
+	guard         *Guard
 }
 
-func New(client metalgo.Client, k8sclient clientset.Interface, projectID string) *MetalService {
-	machineByUUIDCache := cache.New(time.Minute, func(ctx context.Context, id string) (*models.V1MachineResponse, error) {
-		resp, err := client.Machine().FindMachine(machine.NewFindMachineParams().WithContext(ctx).WithID(id), nil)
+func New(backend Backend, k8sclient clientset.Interface, projectID string, metalAPIHealth *health.MetalAPI, guard *Guard) *MetalService {
+	ms := &MetalService{
+		backend:   backend,
+		k8sclient: k8sclient,
+		health:    metalAPIHealth,
+		guard:     guard,
+	}
+	ms.machineByUUIDCache = cache.New(time.Minute, func(ctx context.Context, id string) (*models.V1MachineResponse, error) {
+		markCacheMiss(ctx)
+
+		ctx, done, err := ms.observe(ctx, "find_machine")
+		if err != nil {
+			return nil, err
+		}
+		m, err := backend.FindMachine(ctx, id)
+		done(err)
 		if err != nil {
 			return nil, err
 		}
 
-		if resp.Payload.Allocation == nil {
+		if m.Allocation == nil {
 			return nil, fmt.Errorf("machine %q is not allocated", id)
 		}
-		if resp.Payload.Allocation.Project == nil {
+		if m.Allocation.Project == nil {
 			return nil, fmt.Errorf("machine %q allocation does not have a project", id)
 		}
-		if *resp.Payload.Allocation.Project != projectID {
+		if *m.Allocation.Project != projectID {
 			return nil, fmt.Errorf("machine %q is allocated in the wrong project: %q", id, projectID)
 		}
 
-		return resp.Payload, nil
+		return m, nil
 	})
-	machineByHostnameCache := cache.New(time.Minute, func(ctx context.Context, hostname string) (*models.V1MachineResponse, error) {
-		resp, err := client.Machine().FindMachines(machine.NewFindMachinesParams().WithContext(ctx).WithBody(&models.V1MachineFindRequest{
+	ms.machineByHostnameCache = cache.New(time.Minute, func(ctx context.Context, hostname string) (*models.V1MachineResponse, error) {
+		markCacheMiss(ctx)
+
+		ctx, done, err := ms.observe(ctx, "find_machines")
+		if err != nil {
+			return nil, err
+		}
+		machines, err := backend.FindMachines(ctx, &models.V1MachineFindRequest{
 			AllocationHostname: hostname,
 			AllocationProject:  projectID,
-		}), nil)
+		})
+		done(err)
 		if err != nil {
 			return nil, err
 		}
-		if len(resp.Payload) != 1 {
+		if len(machines) != 1 {
 			return nil, fmt.Errorf("not exactly one machine was found for hostname:%q", hostname)
 		}
-		return resp.Payload[0], nil
+		return machines[0], nil
 	})
-	ms := &MetalService{
-		client:                 client,
-		k8sclient:              k8sclient,
-		machineByUUIDCache:     machineByUUIDCache,
-		machineByHostnameCache: machineByHostnameCache,
-	}
 	return ms
 }
 
@@ -99,7 +124,7 @@ func (ms *MetalService) GetMachineFromProviderID(ctx context.Context, providerID
 		return nil, err
 	}
 
-	machine, err := ms.machineByUUIDCache.Get(ctx, id)
+	machine, err := ms.getFromCache(ctx, "uuid", ms.machineByUUIDCache, id)
 	if err != nil {
 		return nil, err
 	}
@@ -126,29 +151,160 @@ func (ms *MetalService) GetMachineFromNode(ctx context.Context, node *v1.Node) (
 		if err != nil {
 			return nil, err
 		}
-		machine, err = ms.machineByUUIDCache.Get(ctx, id)
+		machine, err = ms.getFromCache(ctx, "uuid", ms.machineByUUIDCache, id)
 	} else {
-		machine, err = ms.machineByHostnameCache.Get(ctx, node.Name)
+		machine, err = ms.getFromCache(ctx, "hostname", ms.machineByHostnameCache, node.Name)
 	}
 	return machine, err
 }
 
+// GetMachineIPMI returns the details of the bmc of the machine with the given id, nil if the machine has no bmc.
+// The details are not cached as the power state is only requested for nodes which are not ready.
+func (ms *MetalService) GetMachineIPMI(ctx context.Context, id string) (*models.V1MachineIPMI, error) {
+	ctx, done, err := ms.observe(ctx, "find_ipmi_machine")
+	if err != nil {
+		return nil, err
+	}
+	ipmi, err := ms.backend.FindMachineIPMI(ctx, id)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	return ipmi, nil
+}
+
+// GetMachinePowerState returns the power state of the machine with the given id as reported by its bmc.
+func (ms *MetalService) GetMachinePowerState(ctx context.Context, id string) (string, error) {
+	ipmi, err := ms.GetMachineIPMI(ctx, id)
+	if err != nil || ipmi == nil {
+		return "", err
+	}
+	return deref(ipmi.Powerstate), nil
+}
+
 // UpdateMachineTags sets the machine tags.
-func (ms *MetalService) UpdateMachineTags(m *string, tags []string) error {
+func (ms *MetalService) UpdateMachineTags(ctx context.Context, m *string, tags []string) error {
+	if m == nil {
+		return fmt.Errorf("machine is nil")
+	}
+	if err := ms.health.CheckMutation("update machine tags"); err != nil {
+		return err
+	}
+
+	ctx, done, err := ms.observe(ctx, "update_machine")
+	if err != nil {
+		return err
+	}
+	err = ms.backend.UpdateMachineTags(ctx, *m, tags)
+	done(err)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateMachineSSHKeys sets the ssh public keys of the machine.
+func (ms *MetalService) UpdateMachineSSHKeys(ctx context.Context, m *string, sshPublicKeys []string) error {
 	if m == nil {
 		return fmt.Errorf("machine is nil")
 	}
+	if err := ms.health.CheckMutation("update machine ssh public keys"); err != nil {
+		return err
+	}
 
-	_, err := ms.client.Machine().UpdateMachine(machine.NewUpdateMachineParams().WithBody(&models.V1MachineUpdateRequest{
-		ID:   m,
-		Tags: tags,
-	}), nil)
+	ctx, done, err := ms.observe(ctx, "update_machine")
+	if err != nil {
+		return err
+	}
+	err = ms.backend.UpdateMachineSSHKeys(ctx, *m, sshPublicKeys)
+	done(err)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// observe admits the given metal-api operation through the guard, starts a span for it and returns a function
+// which records the outcome of the operation in the guard, the span and the metrics.
+func (ms *MetalService) observe(ctx context.Context, operation string) (context.Context, func(error), error) {
+	ctx, span := tracing.Start(ctx, "metal-api "+operation)
+
+	admitted, err := ms.guard.admit(ctx, operation)
+	if err != nil {
+		tracing.End(span, err)
+		return ctx, nil, err
+	}
+
+	start := time.Now()
+	return ctx, func(err error) {
+		admitted(err)
+		metrics.ObserveMetalAPIRequest(operation, start, err)
+		tracing.End(span, err)
+		if isAuthError(err) {
+			klog.Errorf("metal-api rejected the credentials for %s, the credentials may have been rotated or revoked: %v", operation, err)
+		}
+	}, nil
+}
+
+// isAuthError returns true if the metal-api rejected a request because of missing permissions or invalid credentials.
+func isAuthError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var coder interface{ Code() int }
+	if errors.As(err, &coder) {
+		return coder.Code() == http.StatusUnauthorized || coder.Code() == http.StatusForbidden
+	}
+
+	var apiErr *runtime.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.IsCode(http.StatusUnauthorized) || apiErr.IsCode(http.StatusForbidden)
+	}
+
+	return false
+}
+
+type cacheMissKey struct{}
+
+// getFromCache looks up the given key in the cache and records whether the lookup was a cache hit or miss.
+// While the metal-api is unavailable, the last successfully fetched machine is returned if the lookup fails.
+func (ms *MetalService) getFromCache(ctx context.Context, name string, c *cache.Cache[string, *models.V1MachineResponse], key string) (*models.V1MachineResponse, error) {
+	miss := false
+	m, err := c.Get(context.WithValue(ctx, cacheMissKey{}, &miss), key)
+	metrics.ObserveMachineCacheRequest(name, miss)
+
+	staleKey := name + "/" + key
+	if err == nil {
+		ms.staleMachines.Store(staleKey, m)
+		return m, nil
+	}
+
+	if !ms.health.Available() {
+		if stale, ok := ms.staleMachines.Load(staleKey); ok {
+			klog.V(2).Infof("metal-api is unavailable, using last known machine for %s %q: %v", name, key, err)
+			return stale.(*models.V1MachineResponse), nil
+		}
+	}
+
+	return nil, err
+}
+
+// ForgetNode removes the last known machine of the given node, it must be called when the node is deleted.
+func (ms *MetalService) ForgetNode(node *v1.Node) {
+	ms.staleMachines.Delete("hostname/" + node.Name)
+	if id, err := decodeMachineIDFromProviderID(node.Spec.ProviderID); err == nil {
+		ms.staleMachines.Delete("uuid/" + id)
+	}
+}
+
+// markCacheMiss is called from the fetch functions of the caches, which are only invoked on cache misses.
+func markCacheMiss(ctx context.Context) {
+	if miss, ok := ctx.Value(cacheMissKey{}).(*bool); ok {
+		*miss = true
+	}
+}
+
 // machineIDFromProviderID returns a machine's ID from providerID.
 //
 // The providerID spec should be retrievable from the Kubernetes
@@ -1,6 +1,16 @@
 package metal
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/metal-stack/metal-go/api/models"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/metal-stack/metal-ccm/pkg/health"
+	"github.com/metal-stack/metal-ccm/pkg/resources/metal/fake"
+)
 
 func Test_decodeMachineIDFromProviderID(t *testing.T) {
 	tests := []struct {
@@ -66,3 +76,42 @@ func Test_decodeMachineIDFromProviderID(t *testing.T) {
 		})
 	}
 }
+
+func TestMetalService_ForgetNode(t *testing.T) {
+	api := fake.New()
+	api.AddMachine(&models.V1MachineResponse{
+		ID: new("machine-a"),
+		Allocation: &models.V1MachineAllocation{
+			Hostname: new("node-a"),
+			Project:  new("project-a"),
+		},
+	})
+	ms := New(NewMetalGoBackend(api.Client()), k8sfake.NewSimpleClientset(), "project-a", health.NewMetalAPI(3), nil)
+
+	staleMachines := func() int {
+		count := 0
+		ms.staleMachines.Range(func(_, _ any) bool {
+			count++
+			return true
+		})
+		return count
+	}
+
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}}
+	if _, err := ms.GetMachineFromNode(t.Context(), node); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	node.Spec.ProviderID = "metal://partition-a/machine-a"
+	if _, err := ms.GetMachineFromNode(t.Context(), node); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := staleMachines(); got != 2 {
+		t.Fatalf("expected the machine to be kept by hostname and id, got %d entries", got)
+	}
+
+	ms.ForgetNode(node)
+
+	if got := staleMachines(); got != 0 {
+		t.Errorf("expected the machine of the deleted node to be forgotten, got %d entries", got)
+	}
+}
@@ -0,0 +1,60 @@
+package metal
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/metal-stack/metal-go/api/models"
+	"github.com/metal-stack/metal-lib/pkg/tag"
+
+	"github.com/metal-stack/metal-ccm/pkg/resources/constants"
+)
+
+// TopologyLabels returns the rack, chassis and switch pair labels of the given machine, which allow to spread workloads across failure domains.
+// The rack is taken from the machine and falls back to the rack tag, the switch pair is derived from the mac addresses of the switches
+// which the machine nics are connected to. Topology information which is unknown or does not form a valid label value is omitted.
+func TopologyLabels(machine *models.V1MachineResponse) map[string]string {
+	labels := map[string]string{}
+	if machine == nil {
+		return labels
+	}
+
+	tags := tag.NewTagMap(machine.Tags)
+
+	rack := machine.Rackid
+	if rack == "" {
+		rack, _ = tags.Value(tag.MachineRack)
+	}
+	chassis, _ := tags.Value(tag.MachineChassis)
+
+	setValidLabels(labels, map[string]string{
+		constants.TopologyRackLabel:       rack,
+		constants.TopologyChassisLabel:    chassis,
+		constants.TopologySwitchPairLabel: switchPair(machine.Hardware),
+	})
+
+	return labels
+}
+
+// switchPair returns the sorted mac addresses of the switches the machine is connected to, joined by "-" and without ":".
+func switchPair(hw *models.V1MachineHardware) string {
+	if hw == nil {
+		return ""
+	}
+
+	var switches []string
+	for _, nic := range hw.Nics {
+		if nic == nil {
+			continue
+		}
+		for _, neighbor := range nic.Neighbors {
+			if neighbor == nil || neighbor.Mac == nil || *neighbor.Mac == "" {
+				continue
+			}
+			switches = append(switches, strings.ToLower(strings.ReplaceAll(*neighbor.Mac, ":", "")))
+		}
+	}
+	slices.Sort(switches)
+
+	return strings.Join(slices.Compact(switches), "-")
+}
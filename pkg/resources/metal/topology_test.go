@@ -0,0 +1,76 @@
+package metal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/metal-stack/metal-go/api/models"
+	"github.com/metal-stack/metal-lib/pkg/tag"
+
+	"github.com/metal-stack/metal-ccm/pkg/resources/constants"
+)
+
+func TestTopologyLabels(t *testing.T) {
+	nic := func(neighborMacs ...string) *models.V1MachineNic {
+		n := &models.V1MachineNic{Name: new("lan0")}
+		for _, mac := range neighborMacs {
+			n.Neighbors = append(n.Neighbors, &models.V1MachineNic{Mac: new(mac)})
+		}
+		return n
+	}
+
+	tests := []struct {
+		name    string
+		machine *models.V1MachineResponse
+		want    map[string]string
+	}{
+		{
+			name:    "no machine",
+			machine: nil,
+			want:    map[string]string{},
+		},
+		{
+			name: "rack, chassis and switch pair",
+			machine: &models.V1MachineResponse{
+				Rackid: "rack-1",
+				Tags:   []string{tag.MachineChassis + "=chassis-1"},
+				Hardware: &models.V1MachineHardware{
+					Nics: []*models.V1MachineNic{nic("AA:BB:CC:00:00:02"), nic("aa:bb:cc:00:00:01"), nic()},
+				},
+			},
+			want: map[string]string{
+				constants.TopologyRackLabel:       "rack-1",
+				constants.TopologyChassisLabel:    "chassis-1",
+				constants.TopologySwitchPairLabel: "aabbcc000001-aabbcc000002",
+			},
+		},
+		{
+			name: "rack from tag and duplicate neighbors",
+			machine: &models.V1MachineResponse{
+				Tags: []string{tag.MachineRack + "=rack-2"},
+				Hardware: &models.V1MachineHardware{
+					Nics: []*models.V1MachineNic{nic("aa:bb:cc:00:00:01", "aa:bb:cc:00:00:01")},
+				},
+			},
+			want: map[string]string{
+				constants.TopologyRackLabel:       "rack-2",
+				constants.TopologySwitchPairLabel: "aabbcc000001",
+			},
+		},
+		{
+			name: "invalid label values are omitted",
+			machine: &models.V1MachineResponse{
+				Rackid: "rack 1",
+			},
+			want: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TopologyLabels(tt.machine)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("diff = %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package metal
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/go-openapi/runtime"
+	httptransport "github.com/go-openapi/runtime/client"
+	"github.com/go-openapi/strfmt"
+	metalgo "github.com/metal-stack/metal-go"
+	"github.com/metal-stack/metal-go/api/client"
+	"github.com/metal-stack/metal-go/api/client/audit"
+	"github.com/metal-stack/metal-go/api/client/filesystemlayout"
+	"github.com/metal-stack/metal-go/api/client/firewall"
+	"github.com/metal-stack/metal-go/api/client/firmware"
+	"github.com/metal-stack/metal-go/api/client/health"
+	"github.com/metal-stack/metal-go/api/client/image"
+	"github.com/metal-stack/metal-go/api/client/ip"
+	"github.com/metal-stack/metal-go/api/client/machine"
+	"github.com/metal-stack/metal-go/api/client/network"
+	"github.com/metal-stack/metal-go/api/client/partition"
+	"github.com/metal-stack/metal-go/api/client/project"
+	"github.com/metal-stack/metal-go/api/client/size"
+	"github.com/metal-stack/metal-go/api/client/sizeimageconstraint"
+	"github.com/metal-stack/metal-go/api/client/switch_operations"
+	"github.com/metal-stack/metal-go/api/client/tenant"
+	"github.com/metal-stack/metal-go/api/client/user"
+	"github.com/metal-stack/metal-go/api/client/version"
+	"github.com/metal-stack/metal-go/api/client/vpn"
+	"github.com/metal-stack/security"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/metal-stack/metal-ccm/pkg/cloudconfig"
+)
+
+// newHTTPClient returns the http client for the metal-api with the tls, proxy and timeout settings of the given config.
+// The ca and client certificate files are read once, invalid files are reported with the name of the config field.
+func newHTTPClient(cfg cloudconfig.Transport) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read metalAPI.transport.caFile: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("metalAPI.transport.caFile %q does not contain any pem encoded certificate", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load metalAPI.transport.clientCertFile and clientKeyFile: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if cfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metalAPI.transport.proxyURL: %w", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.TLSClientConfig = tlsConfig
+	transport.DialContext = (&net.Dialer{
+		Timeout:   durationOrZero(cfg.DialTimeout),
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = durationOrZero(cfg.TLSHandshakeTimeout)
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   durationOrZero(cfg.Timeout),
+	}, nil
+}
+
+// newDriver returns a metal-go client for the given url and credentials which sends its requests through the given http client.
+// It is equivalent to metalgo.NewDriver, which does not allow to customize the transport.
+func newDriver(baseURL, token, hmac, hmacAuthType string, httpClient *http.Client) (metalgo.Client, error) {
+	parsedURL, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsedURL.Host == "" {
+		return nil, fmt.Errorf("invalid url:%s, must be in the form scheme://host[:port]/basepath", baseURL)
+	}
+
+	transport := httptransport.NewWithClient(parsedURL.Host, parsedURL.Path, []string{parsedURL.Scheme}, httpClient)
+
+	switch {
+	case hmac != "":
+		auth := security.NewHMACAuth(hmacAuthType, []byte(hmac))
+		transport.DefaultAuthentication = runtime.ClientAuthInfoWriterFunc(func(rq runtime.ClientRequest, _ strfmt.Registry) error {
+			auth.AddAuthToClientRequest(rq, time.Now())
+			return nil
+		})
+	case token != "":
+		transport.DefaultAuthentication = runtime.ClientAuthInfoWriterFunc(func(rq runtime.ClientRequest, _ strfmt.Registry) error {
+			security.AddUserTokenToClientRequest(rq, token)
+			return nil
+		})
+	}
+
+	return &apiClient{c: client.New(transport, strfmt.Default)}, nil
+}
+
+// IsTLSVerificationError returns true if the certificate of the metal-api could not be verified,
+// which usually means that the ca of the metal-api is missing in metalAPI.transport.caFile.
+func IsTLSVerificationError(err error) bool {
+	var (
+		verificationErr *tls.CertificateVerificationError
+		unknownAuthErr  x509.UnknownAuthorityError
+		hostnameErr     x509.HostnameError
+		invalidErr      x509.CertificateInvalidError
+	)
+	return errors.As(err, &verificationErr) || errors.As(err, &unknownAuthErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
+
+func durationOrZero(d *metav1.Duration) time.Duration {
+	if d == nil {
+		return 0
+	}
+	return d.Duration
+}
+
+// apiClient implements metalgo.Client on top of the generated metal-api client.
+type apiClient struct {
+	c *client.MetalAPI
+}
+
+func (a *apiClient) Audit() audit.ClientService {
+	return a.c.Audit
+}
+func (a *apiClient) Filesystemlayout() filesystemlayout.ClientService {
+	return a.c.Filesystemlayout
+}
+func (a *apiClient) Firewall() firewall.ClientService {
+	return a.c.Firewall
+}
+func (a *apiClient) Firmware() firmware.ClientService {
+	return a.c.Firmware
+}
+func (a *apiClient) Health() health.ClientService {
+	return a.c.Health
+}
+func (a *apiClient) Image() image.ClientService {
+	return a.c.Image
+}
+func (a *apiClient) IP() ip.ClientService {
+	return a.c.IP
+}
+func (a *apiClient) Machine() machine.ClientService {
+	return a.c.Machine
+}
+func (a *apiClient) Network() network.ClientService {
+	return a.c.Network
+}
+func (a *apiClient) Partition() partition.ClientService {
+	return a.c.Partition
+}
+func (a *apiClient) Project() project.ClientService {
+	return a.c.Project
+}
+func (a *apiClient) Size() size.ClientService {
+	return a.c.Size
+}
+func (a *apiClient) Sizeimageconstraint() sizeimageconstraint.ClientService {
+	return a.c.Sizeimageconstraint
+}
+func (a *apiClient) SwitchOperations() switch_operations.ClientService {
+	return a.c.SwitchOperations
+}
+func (a *apiClient) Tenant() tenant.ClientService {
+	return a.c.Tenant
+}
+func (a *apiClient) User() user.ClientService {
+	return a.c.User
+}
+func (a *apiClient) Version() version.ClientService {
+	return a.c.Version
+}
+func (a *apiClient) VPN() vpn.ClientService {
+	return a.c.Vpn
+}
@@ -0,0 +1,91 @@
+package metal
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/metal-stack/metal-ccm/pkg/cloudconfig"
+)
+
+func TestNewClient_Transport(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"healthy","message":""}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty.crt")
+	err = os.WriteFile(emptyFile, []byte("no certificate"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		transport     cloudconfig.Transport
+		wantClientErr string
+		wantTLSErr    bool
+	}{
+		{
+			name:      "private ca",
+			transport: cloudconfig.Transport{CAFile: caFile},
+		},
+		{
+			name:       "unknown ca",
+			transport:  cloudconfig.Transport{},
+			wantTLSErr: true,
+		},
+		{
+			name:          "ca file without certificates",
+			transport:     cloudconfig.Transport{CAFile: emptyFile},
+			wantClientErr: "does not contain any pem encoded certificate",
+		},
+		{
+			name:          "missing client certificate",
+			transport:     cloudconfig.Transport{ClientCertFile: filepath.Join(dir, "tls.crt"), ClientKeyFile: filepath.Join(dir, "tls.key")},
+			wantClientErr: "unable to load metalAPI.transport.clientCertFile",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClient(cloudconfig.MetalAPI{
+				URL:       srv.URL + "/metal",
+				Token:     "token",
+				Transport: tt.transport,
+			})
+			if tt.wantClientErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantClientErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantClientErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			err = NewMetalGoBackend(c).Health(context.Background())
+			if got := IsTLSVerificationError(err); got != tt.wantTLSErr {
+				t.Errorf("IsTLSVerificationError() = %v, want %v, err: %v", got, tt.wantTLSErr, err)
+			}
+			if !tt.wantTLSErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
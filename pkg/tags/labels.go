@@ -0,0 +1,173 @@
+package tags
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+var invalidLabelChars = regexp.MustCompile(`[^-A-Za-z0-9_.]`)
+
+// LabelMapper maps machine tags of the form key=value to node labels.
+type LabelMapper struct {
+	include  []*regexp.Regexp
+	exclude  []*regexp.Regexp
+	rewrites map[string]string
+}
+
+// SkippedTag is a machine tag which was not mapped to a node label.
+type SkippedTag struct {
+	Tag    string
+	Reason string
+}
+
+func (s SkippedTag) String() string {
+	return fmt.Sprintf("%q (%s)", s.Tag, s.Reason)
+}
+
+// NewLabelMapper returns a label mapper which only maps tags whose key matches one of the include patterns, or all tags if there are none,
+// and never maps tags whose key matches one of the exclude patterns. Patterns are globs where * matches any characters,
+// or regular expressions if they are enclosed in slashes. The longest matching key prefix of the rewrites is replaced.
+func NewLabelMapper(include, exclude []string, rewrites map[string]string) (*LabelMapper, error) {
+	var errs []error
+
+	compile := func(patterns []string) []*regexp.Regexp {
+		var res []*regexp.Regexp
+		for _, p := range patterns {
+			r, err := compilePattern(p)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			res = append(res, r)
+		}
+		return res
+	}
+
+	m := &LabelMapper{
+		include:  compile(include),
+		exclude:  compile(exclude),
+		rewrites: rewrites,
+	}
+
+	for from := range rewrites {
+		if from == "" {
+			errs = append(errs, errors.New("the prefix of a rewrite must not be empty"))
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return m, nil
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		r, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		return r, nil
+	}
+
+	glob := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+	return regexp.Compile("^" + glob + "$")
+}
+
+// Labels returns the node labels for the given machine tags and the tags which were skipped.
+// Tags without a value and taints are no label candidates and are therefore not reported.
+func (m *LabelMapper) Labels(tags []string) (map[string]string, []SkippedTag) {
+	var (
+		labels  = make(map[string]string)
+		skipped []SkippedTag
+	)
+
+	for _, t := range tags {
+		key, value, found := strings.Cut(t, "=")
+		if !found || IsTaint(t) {
+			continue
+		}
+
+		if matchesAny(m.exclude, key) {
+			skipped = append(skipped, SkippedTag{Tag: t, Reason: "excluded"})
+			continue
+		}
+		if len(m.include) > 0 && !matchesAny(m.include, key) {
+			skipped = append(skipped, SkippedTag{Tag: t, Reason: "not included"})
+			continue
+		}
+
+		key, value, err := sanitizeLabel(m.rewrite(key), value)
+		if err != nil {
+			skipped = append(skipped, SkippedTag{Tag: t, Reason: err.Error()})
+			continue
+		}
+
+		labels[key] = value
+	}
+
+	return labels, skipped
+}
+
+func (m *LabelMapper) rewrite(key string) string {
+	longest := ""
+	for from := range m.rewrites {
+		if strings.HasPrefix(key, from) && len(from) > len(longest) {
+			longest = from
+		}
+	}
+	if longest == "" {
+		return key
+	}
+	return m.rewrites[longest] + strings.TrimPrefix(key, longest)
+}
+
+func matchesAny(patterns []*regexp.Regexp, key string) bool {
+	for _, p := range patterns {
+		if p.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeLabel replaces invalid characters of the label name and value, truncates them to the maximum length
+// and returns an error if the result is still no valid label.
+func sanitizeLabel(key, value string) (string, string, error) {
+	prefix, name, hasPrefix := strings.Cut(key, "/")
+	if !hasPrefix {
+		prefix, name = "", key
+	}
+
+	name = sanitizeLabelValue(name)
+	if hasPrefix {
+		key = strings.ToLower(prefix) + "/" + name
+	} else {
+		key = name
+	}
+	value = sanitizeLabelValue(value)
+
+	var errs []string
+	errs = append(errs, validation.IsQualifiedName(key)...)
+	errs = append(errs, validation.IsValidLabelValue(value)...)
+	if len(errs) > 0 {
+		return "", "", fmt.Errorf("invalid label: %s", strings.Join(errs, ", "))
+	}
+
+	return key, value, nil
+}
+
+func sanitizeLabelValue(v string) string {
+	v = invalidLabelChars.ReplaceAllString(v, "-")
+	if len(v) > validation.LabelValueMaxLength {
+		v = v[:validation.LabelValueMaxLength]
+	}
+	return strings.TrimFunc(v, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+}
@@ -0,0 +1,86 @@
+package tags
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLabelMapper_Labels(t *testing.T) {
+	tests := []struct {
+		name        string
+		include     []string
+		exclude     []string
+		rewrites    map[string]string
+		tags        []string
+		want        map[string]string
+		wantSkipped map[string]string
+	}{
+		{
+			name: "all tags with a value are mapped by default",
+			tags: []string{"partition=apartition", "machine=amachineid", "nolabel"},
+			want: map[string]string{"machine": "amachineid", "partition": "apartition"},
+		},
+		{
+			name:        "excluded glob",
+			exclude:     []string{"networking.gardener.cloud/*"},
+			tags:        []string{"partition=apartition", "networking.gardener.cloud/node-local-dns-enabled=false"},
+			want:        map[string]string{"partition": "apartition"},
+			wantSkipped: map[string]string{"networking.gardener.cloud/node-local-dns-enabled=false": "excluded"},
+		},
+		{
+			name:        "include regex and exclude takes precedence",
+			include:     []string{`/^topology\.metal-stack\.io\/.+$/`},
+			exclude:     []string{"topology.metal-stack.io/switch"},
+			tags:        []string{"topology.metal-stack.io/rack=rack-1", "topology.metal-stack.io/switch=leaf01", "partition=apartition"},
+			want:        map[string]string{"topology.metal-stack.io/rack": "rack-1"},
+			wantSkipped: map[string]string{"topology.metal-stack.io/switch=leaf01": "excluded", "partition=apartition": "not included"},
+		},
+		{
+			name:     "longest prefix is rewritten",
+			rewrites: map[string]string{"machine.metal-stack.io/": "metal-stack.io/", "machine.metal-stack.io/network.": "network.metal-stack.io/"},
+			tags:     []string{"machine.metal-stack.io/size=c1-large", "machine.metal-stack.io/network.primary=internet"},
+			want:     map[string]string{"metal-stack.io/size": "c1-large", "network.metal-stack.io/primary": "internet"},
+		},
+		{
+			name: "invalid characters are sanitized",
+			tags: []string{"Owner.Example.COM/team name=dev ops!", "description=" + strings.Repeat("x", 70)},
+			want: map[string]string{"owner.example.com/team-name": "dev-ops", "description": strings.Repeat("x", 63)},
+		},
+		{
+			name:        "invalid prefix is skipped",
+			tags:        []string{"in valid/name=a"},
+			want:        map[string]string{},
+			wantSkipped: map[string]string{"in valid/name=a": "invalid label: prefix part"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewLabelMapper(tt.include, tt.exclude, tt.rewrites)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, skipped := m.Labels(tt.tags)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("diff = %v", diff)
+			}
+			if len(skipped) != len(tt.wantSkipped) {
+				t.Errorf("skipped tags = %v, want %v", skipped, tt.wantSkipped)
+			}
+			for _, s := range skipped {
+				if !strings.HasPrefix(s.Reason, tt.wantSkipped[s.Tag]) || tt.wantSkipped[s.Tag] == "" {
+					t.Errorf("unexpected skipped tag %s, want %v", s, tt.wantSkipped)
+				}
+			}
+		})
+	}
+}
+
+func TestNewLabelMapper(t *testing.T) {
+	_, err := NewLabelMapper([]string{"/[/"}, nil, map[string]string{"": "a/"})
+	if err == nil {
+		t.Fatal("expected an error for an invalid regex and an empty rewrite prefix")
+	}
+}
@@ -2,11 +2,39 @@ package tags
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	t "github.com/metal-stack/metal-lib/pkg/tag"
 )
 
+const (
+	// ClusterAutoAssign tag to identify ips which are pre-allocated for the auto-assign pool of a cluster
+	ClusterAutoAssign = t.ClusterID + "/auto-assign"
+)
+
+// BuildClusterAutoAssignTag returns the ClusterAutoAssign tag for the given cluster.
+func BuildClusterAutoAssignTag(clusterID string) string {
+	return fmt.Sprintf("%s=%s", ClusterAutoAssign, clusterID)
+}
+
+// IsAutoAssign returns true if the given tags contain the ClusterAutoAssign tag of the given cluster.
+func IsAutoAssign(tags []string, clusterID string) bool {
+	return slices.Contains(tags, BuildClusterAutoAssignTag(clusterID))
+}
+
+// IsAutoAssignOfOtherCluster returns true if the given tags contain a ClusterAutoAssign tag of another than the given cluster.
+func IsAutoAssignOfOtherCluster(tags []string, clusterID string) bool {
+	_, ok := t.NewTagMap(tags).Value(ClusterAutoAssign)
+	return ok && !IsAutoAssign(tags, clusterID)
+}
+
+// IsUsedByService returns true if the given tags contain a ClusterServiceFQN tag, i.e. the ip is used by a service.
+func IsUsedByService(tags []string) bool {
+	_, ok := t.NewTagMap(tags).Value(t.ClusterServiceFQN)
+	return ok
+}
+
 // BuildClusterServiceFQNTag returns the ClusterServiceFQN tag populated with the given arguments.
 func BuildClusterServiceFQNTag(clusterID string, namespace, serviceName string) string {
 	return fmt.Sprintf("%s=%s/%s/%s", t.ClusterServiceFQN, clusterID, namespace, serviceName)
@@ -40,3 +40,37 @@ func TestIsMemberOfCluster(t *testing.T) {
 		})
 	}
 }
+
+func TestIsAutoAssign(t *testing.T) {
+	tests := []struct {
+		name             string
+		tags             []string
+		wantAutoAssign   bool
+		wantOtherCluster bool
+	}{
+		{
+			name:           "auto-assign ip of this cluster",
+			tags:           []string{BuildClusterAutoAssignTag("this-cluster")},
+			wantAutoAssign: true,
+		},
+		{
+			name:             "auto-assign ip of another cluster",
+			tags:             []string{BuildClusterAutoAssignTag("other-cluster")},
+			wantOtherCluster: true,
+		},
+		{
+			name: "ip of a service",
+			tags: []string{BuildClusterServiceFQNTag("this-cluster", "default", "svc")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsAutoAssign(tt.tags, "this-cluster"); got != tt.wantAutoAssign {
+				t.Errorf("IsAutoAssign() = %v, want %v", got, tt.wantAutoAssign)
+			}
+			if got := IsAutoAssignOfOtherCluster(tt.tags, "this-cluster"); got != tt.wantOtherCluster {
+				t.Errorf("IsAutoAssignOfOtherCluster() = %v, want %v", got, tt.wantOtherCluster)
+			}
+		})
+	}
+}
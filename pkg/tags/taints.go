@@ -0,0 +1,76 @@
+package tags
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// TaintPrefix is the reserved prefix of machine tags which are synced to node taints instead of node labels.
+// The remainder of the tag has the form of a kubectl taint, key[=value]:Effect, e.g. taint.metal-stack.io/metal-stack.io/faulty-disk=true:NoSchedule
+const TaintPrefix = "taint.metal-stack.io/"
+
+// IsTaint returns true if the given tag describes a node taint.
+func IsTaint(tag string) bool {
+	return strings.HasPrefix(tag, TaintPrefix)
+}
+
+// Taints returns the node taints of the given machine tags and the taint tags which are invalid.
+func Taints(tags []string) ([]v1.Taint, []SkippedTag) {
+	var (
+		taints  []v1.Taint
+		skipped []SkippedTag
+	)
+
+	for _, t := range tags {
+		if !IsTaint(t) {
+			continue
+		}
+
+		taint, err := parseTaint(strings.TrimPrefix(t, TaintPrefix))
+		if err != nil {
+			skipped = append(skipped, SkippedTag{Tag: t, Reason: err.Error()})
+			continue
+		}
+
+		// a later tag with the same key and effect wins, as a node can not have both
+		taints = slices.DeleteFunc(taints, func(existing v1.Taint) bool {
+			return existing.MatchTaint(&taint)
+		})
+		taints = append(taints, taint)
+	}
+
+	return taints, skipped
+}
+
+func parseTaint(spec string) (v1.Taint, error) {
+	keyValue, effect, found := strings.Cut(spec, ":")
+	if !found {
+		return v1.Taint{}, fmt.Errorf("invalid taint: missing effect, expected key[=value]:Effect")
+	}
+
+	key, value, _ := strings.Cut(keyValue, "=")
+
+	var errs []string
+	errs = append(errs, validation.IsQualifiedName(key)...)
+	if value != "" {
+		errs = append(errs, validation.IsValidLabelValue(value)...)
+	}
+	switch v1.TaintEffect(effect) {
+	case v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+	default:
+		errs = append(errs, fmt.Sprintf("unknown effect %q, must be one of %s, %s or %s", effect, v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute))
+	}
+	if len(errs) > 0 {
+		return v1.Taint{}, fmt.Errorf("invalid taint: %s", strings.Join(errs, ", "))
+	}
+
+	return v1.Taint{
+		Key:    key,
+		Value:  value,
+		Effect: v1.TaintEffect(effect),
+	}, nil
+}
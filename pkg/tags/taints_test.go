@@ -0,0 +1,72 @@
+package tags
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestTaints(t *testing.T) {
+	tests := []struct {
+		name        string
+		tags        []string
+		want        []v1.Taint
+		wantSkipped map[string]string
+	}{
+		{
+			name: "taints with and without value",
+			tags: []string{
+				"partition=apartition",
+				TaintPrefix + "metal-stack.io/faulty-disk=true:NoSchedule",
+				TaintPrefix + "dedicated:NoExecute",
+			},
+			want: []v1.Taint{
+				{Key: "metal-stack.io/faulty-disk", Value: "true", Effect: v1.TaintEffectNoSchedule},
+				{Key: "dedicated", Effect: v1.TaintEffectNoExecute},
+			},
+		},
+		{
+			name: "later tag with same key and effect wins",
+			tags: []string{
+				TaintPrefix + "dedicated=a:NoSchedule",
+				TaintPrefix + "dedicated=b:PreferNoSchedule",
+				TaintPrefix + "dedicated=c:NoSchedule",
+			},
+			want: []v1.Taint{
+				{Key: "dedicated", Value: "b", Effect: v1.TaintEffectPreferNoSchedule},
+				{Key: "dedicated", Value: "c", Effect: v1.TaintEffectNoSchedule},
+			},
+		},
+		{
+			name: "invalid taints are skipped",
+			tags: []string{
+				TaintPrefix + "dedicated=a",
+				TaintPrefix + "dedicated=a:NoWay",
+				TaintPrefix + "in valid:NoSchedule",
+			},
+			wantSkipped: map[string]string{
+				TaintPrefix + "dedicated=a":         "invalid taint: missing effect",
+				TaintPrefix + "dedicated=a:NoWay":   "invalid taint: unknown effect",
+				TaintPrefix + "in valid:NoSchedule": "invalid taint: name part",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, skipped := Taints(tt.tags)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("diff = %v", diff)
+			}
+			if len(skipped) != len(tt.wantSkipped) {
+				t.Errorf("skipped tags = %v, want %v", skipped, tt.wantSkipped)
+			}
+			for _, s := range skipped {
+				if !strings.HasPrefix(s.Reason, tt.wantSkipped[s.Tag]) || tt.wantSkipped[s.Tag] == "" {
+					t.Errorf("unexpected skipped tag %s, want %v", s, tt.wantSkipped)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	ctx, parent := Start(context.Background(), "parent")
+
+	mu := &sync.Mutex{}
+	Lock(ctx, "mutex", mu)
+	mu.Unlock()
+
+	_, child := Start(ctx, "child")
+	End(child, errors.New("metal-api not reachable"))
+
+	End(parent, nil)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+
+	byName := map[string]tracetest.SpanStub{}
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+
+	for _, name := range []string{"lock mutex", "child"} {
+		if byName[name].Parent.SpanID() != byName["parent"].SpanContext.SpanID() {
+			t.Errorf("span %q is not a child of the parent span", name)
+		}
+	}
+
+	if byName["child"].Status.Code != codes.Error {
+		t.Errorf("expected error status on child span, got %v", byName["child"].Status.Code)
+	}
+	if byName["parent"].Status.Code != codes.Unset {
+		t.Errorf("expected unset status on parent span, got %v", byName["parent"].Status.Code)
+	}
+}